@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+	"golang.org/x/term"
+)
+
+// runEncrypt implements "zonocaller encrypt": it reads a secret from stdin
+// (or a prompt) and prints it encrypted with ZONOMI_ENCRYPTION_KEY, with the
+// enc: prefix that marks encrypted config values.
+func runEncrypt(args []string) int {
+	return encryptCommand(args, os.Stdin, os.Stdout, os.Stderr)
+}
+
+// runDecrypt implements "zonocaller decrypt": it reads an encrypted secret
+// from stdin (or a prompt) and prints it decrypted with ZONOMI_ENCRYPTION_KEY.
+func runDecrypt(args []string) int {
+	return decryptCommand(args, os.Stdin, os.Stdout, os.Stderr)
+}
+
+// encryptCommand encrypts a secret read from stdin.
+func encryptCommand(args []string, stdin *os.File, stdout, stderr io.Writer) int {
+
+	flags := flag.NewFlagSet("encrypt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	generateKey := flags.Bool("generate-key", false, "generate a new encryption key instead of using ZONOMI_ENCRYPTION_KEY")
+	keyID := flags.String("key-id", os.Getenv("ZONOMI_ENCRYPTION_KEY_ID"), "ID of the key to encrypt with, recorded in the secret")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: zonocaller encrypt [-generate-key] [-key-id ID] < api_key")
+		fmt.Fprintln(stderr, "Encrypts a secret read from stdin with ZONOMI_ENCRYPTION_KEY (or ZONOMI_ENCRYPTION_KEYS).")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	keyring, err := loadKeyring(*generateKey, *keyID, stdin, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	plaintext, err := readInput("API key", stdin, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	encrypted, err := keyring.Encrypt(*keyID, []byte(plaintext))
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	if *generateKey {
+		if *keyID != "" {
+			fmt.Fprintf(stdout, "ZONOMI_ENCRYPTION_KEY_ID=%s\n", *keyID)
+		}
+		fmt.Fprintf(stdout, "ZONOMI_ENCRYPTION_KEY=%s\n", keyring.Keys[*keyID])
+		fmt.Fprintf(stdout, "ZONOMI_API_KEY=%s%s\n", config.EncryptedPrefix, encrypted)
+		return 0
+	}
+
+	fmt.Fprintln(stdout, config.EncryptedPrefix+encrypted)
+
+	return 0
+}
+
+// decryptCommand decrypts a secret read from stdin.
+func decryptCommand(args []string, stdin *os.File, stdout, stderr io.Writer) int {
+
+	flags := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: zonocaller decrypt < encrypted_api_key")
+		fmt.Fprintln(stderr, "Decrypts a secret read from stdin with ZONOMI_ENCRYPTION_KEY (or ZONOMI_ENCRYPTION_KEYS).")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	keyring, err := loadKeyring(false, "", stdin, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	encrypted, err := readInput("Encrypted API key", stdin, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	plaintext, _, err := keyring.Decrypt("secret", encrypted)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	fmt.Fprintln(stdout, plaintext)
+
+	return 0
+}
+
+// loadKeyring returns a keyring holding a newly generated key under keyID,
+// the keys from the environment, or a key typed at a prompt, in that order of
+// preference.
+func loadKeyring(generate bool, keyID string, stdin *os.File, stderr io.Writer) (config.Keyring, error) {
+
+	if generate {
+		key, err := config.GenerateKey()
+		if err != nil {
+			return config.Keyring{}, err
+		}
+		return config.Keyring{Primary: keyID, Keys: map[string]string{keyID: key}}, nil
+	}
+
+	keyring, err := config.LoadKeyring()
+	if err != nil {
+		return config.Keyring{}, err
+	}
+
+	if len(keyring.Keys) > 0 {
+		return keyring, nil
+	}
+
+	if !term.IsTerminal(int(stdin.Fd())) {
+		return config.Keyring{}, fmt.Errorf("ZONOMI_ENCRYPTION_KEY or ZONOMI_ENCRYPTION_KEY_FILE must be set when stdin is not a terminal")
+	}
+
+	key, err := readInput("Encryption key", stdin, stderr)
+	if err != nil {
+		return config.Keyring{}, err
+	}
+
+	return config.Keyring{Primary: keyID, Keys: map[string]string{keyID: key}}, nil
+}
+
+// readInput reads a value from a terminal prompt without echo, or the first
+// line of stdin when it is not a terminal.
+func readInput(prompt string, stdin *os.File, stderr io.Writer) (string, error) {
+
+	if term.IsTerminal(int(stdin.Fd())) {
+		fmt.Fprintf(stderr, "%s: ", prompt)
+		value, err := term.ReadPassword(int(stdin.Fd()))
+		fmt.Fprintln(stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", strings.ToLower(prompt), err)
+		}
+		return string(value), nil
+	}
+
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read %s: %w", strings.ToLower(prompt), err)
+	}
+
+	value := strings.TrimRight(line, "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("%s is empty", strings.ToLower(prompt))
+	}
+
+	return value, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stdinFile returns a file containing input to use as stdin.
+func stdinFile(t *testing.T, input string) *os.File {
+	path := filepath.Join(t.TempDir(), "stdin")
+	require.NoError(t, os.WriteFile(path, []byte(input), 0600))
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+func TestEncryptDecryptCommands(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("ZONOMI_ENCRYPTION_KEY", "my passphrase")
+
+	// Encrypt
+	var encrypted, stderr bytes.Buffer
+	code := encryptCommand(nil, stdinFile(t, "test-api-key\n"), &encrypted, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.True(t, strings.HasPrefix(encrypted.String(), "enc:$zc2$"))
+
+	// Decrypt
+	var decrypted bytes.Buffer
+	code = decryptCommand(nil, stdinFile(t, encrypted.String()), &decrypted, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "test-api-key\n", decrypted.String())
+}
+
+func TestEncryptCommand_GenerateKey(t *testing.T) {
+	os.Clearenv()
+
+	var stdout, stderr bytes.Buffer
+	code := encryptCommand([]string{"-generate-key"}, stdinFile(t, "test-api-key\n"), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 2)
+	key := strings.TrimPrefix(lines[0], "ZONOMI_ENCRYPTION_KEY=")
+	assert.Len(t, key, 32)
+	assert.True(t, strings.HasPrefix(lines[1], "ZONOMI_API_KEY=enc:$zc2$"))
+
+	// The generated key decrypts the generated secret
+	os.Setenv("ZONOMI_ENCRYPTION_KEY", key)
+	var decrypted bytes.Buffer
+	code = decryptCommand(nil, stdinFile(t, strings.TrimPrefix(lines[1], "ZONOMI_API_KEY=")), &decrypted, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "test-api-key\n", decrypted.String())
+}
+
+func TestEncryptCommand_MissingKey(t *testing.T) {
+	os.Clearenv()
+
+	var stdout, stderr bytes.Buffer
+	code := encryptCommand(nil, stdinFile(t, "test-api-key\n"), &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "ZONOMI_ENCRYPTION_KEY or ZONOMI_ENCRYPTION_KEY_FILE must be set")
+}
+
+func TestDecryptCommand_WrongKey(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("ZONOMI_ENCRYPTION_KEY", "my passphrase")
+
+	var encrypted, stderr bytes.Buffer
+	require.Equal(t, 0, encryptCommand(nil, stdinFile(t, "test-api-key"), &encrypted, &stderr))
+
+	os.Setenv("ZONOMI_ENCRYPTION_KEY", "another passphrase")
+	var stdout bytes.Buffer
+	code := decryptCommand(nil, stdinFile(t, encrypted.String()), &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Empty(t, stdout.String())
+	assert.Contains(t, stderr.String(), "wrong key")
+}
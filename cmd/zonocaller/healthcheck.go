@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// runHealthcheck implements "zonocaller healthcheck": it exits 0 if the
+// running service is alive.
+func runHealthcheck(args []string) int {
+	return healthcheckCommand(args, os.Stdout, os.Stderr)
+}
+
+// healthcheckCommand probes the running service's /livez endpoint at the
+// address derived from HTTP_ADDR, for container health checks. Nothing
+// secret is sent, so the service's certificate is not verified.
+func healthcheckCommand(args []string, stdout, stderr io.Writer) int {
+
+	client := &adminClient{insecure: true}
+	flags := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&client.configFile, "config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	flags.StringVar(&client.addr, "addr", "", "address of the running service, or unix:/path for a Unix socket (default: from HTTP_ADDR)")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: zonocaller healthcheck [-config FILE] [-addr URL]")
+		fmt.Fprintln(stderr, "Exits 0 if the running service is alive.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	_, httpClient, addr, err := client.connect()
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	resp, err := httpClient.Get(addr + "/livez")
+	if err != nil {
+		fmt.Fprintln(stderr, "Error: failed to reach the service:", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintln(stderr, "Error: service responded", resp.Status)
+		return 1
+	}
+
+	fmt.Fprintln(stdout, "OK")
+	return 0
+}
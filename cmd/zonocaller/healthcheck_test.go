@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Drakx/ZonoCaller/internal/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthcheckCommand(t *testing.T) {
+	os.Clearenv()
+
+	mux := http.NewServeMux()
+	mux.Handle("/livez", health.LivezHandler())
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// The address comes from HTTP_ADDR
+	os.Setenv("HTTP_ADDR", server.Listener.Addr().String())
+	var stdout, stderr bytes.Buffer
+	code := healthcheckCommand(nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "OK\n", stdout.String())
+
+	// Any other response is unhealthy
+	stderr.Reset()
+	code = healthcheckCommand([]string{"-addr", server.URL + "/missing"}, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "404")
+
+	// So is a service that isn't listening
+	server.Close()
+	stderr.Reset()
+	code = healthcheckCommand(nil, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "failed to reach the service")
+}
+
+func TestHealthcheckCommand_TLS(t *testing.T) {
+	os.Clearenv()
+
+	server := httptest.NewTLSServer(health.LivezHandler())
+	defer server.Close()
+
+	// A self-signed HTTP_TLS_CERT_FILE is accepted
+	os.Setenv("HTTP_ADDR", server.Listener.Addr().String())
+	os.Setenv("HTTP_TLS_CERT_FILE", "/certs/tls.crt")
+	var stdout, stderr bytes.Buffer
+	code := healthcheckCommand(nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+}
+
+func TestHealthcheckCommand_UnixSocket(t *testing.T) {
+	os.Clearenv()
+
+	path := filepath.Join(t.TempDir(), "zonocaller.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(health.LivezHandler())
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	os.Setenv("HTTP_ADDR", "unix:"+path)
+	var stdout, stderr bytes.Buffer
+	code := healthcheckCommand(nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+}
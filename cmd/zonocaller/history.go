@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/Drakx/ZonoCaller/internal/history"
+	"github.com/Drakx/ZonoCaller/internal/state"
+)
+
+// runHistory implements "zonocaller history": it lists recorded runs and
+// IP change statistics.
+func runHistory(args []string) int {
+	return historyCommand(args, os.Stdout, os.Stderr)
+}
+
+// historyCommand prints the history from the configured state store, or
+// from a JSON-lines log given with -file, filtered by the given flags. Dates
+// are days in the configured timezone. Only the state settings are read from
+// the configuration, so it works without the Zonomi settings and keys.
+func historyCommand(args []string, stdout, stderr io.Writer) int {
+
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	logFile := flags.String("file", "", "read this JSON-lines log instead of the configured state store")
+	since := flags.String("since", "", "only runs at or after this time (RFC 3339 or YYYY-MM-DD)")
+	until := flags.String("until", "", "only runs before this time, or up to the end of this date (RFC 3339 or YYYY-MM-DD)")
+	changes := flags.Bool("changes", false, "only runs where the IP changed")
+	host := flags.String("host", "", "only runs that updated this host")
+	format := flags.String("format", history.FormatTable, "output format: table, json or csv")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: zonocaller history [-file PATH] [-since TIME] [-until TIME] [-changes] [-host HOST] [-format table|json|csv]")
+		fmt.Fprintln(stderr, "Lists recorded runs and IP change statistics.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.LoadState(*configFile)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: failed to load timezone %s: %v\n", cfg.Timezone, err)
+		return 1
+	}
+
+	// Share the HTTP endpoint's parsing so both accept the same values
+	filter, err := history.ParseFilter(url.Values{
+		"since":   {*since},
+		"until":   {*until},
+		"changes": {strconv.FormatBool(*changes)},
+		"host":    {*host},
+	}, loc)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 2
+	}
+
+	store, err := openHistory(*logFile, *cfg)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	defer store.Close()
+
+	entries, err := store.History()
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	matched := history.Query(entries, filter)
+	if err := history.Write(stdout, *format, matched, history.Summarize(matched)); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	return 0
+}
+
+// openHistory opens the log at logFile if set, otherwise the state store
+// configured by cfg.
+func openHistory(logFile string, cfg config.Config) (state.Store, error) {
+
+	if logFile != "" {
+		return state.NewFileStore(logFile), nil
+	}
+
+	return state.OpenReadOnly(cfg)
+}
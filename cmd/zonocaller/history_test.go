@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Drakx/ZonoCaller/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryCommand(t *testing.T) {
+	os.Clearenv()
+
+	outputFile := filepath.Join(t.TempDir(), "ip_log.txt")
+	os.Setenv("OUTPUT_FILE", outputFile)
+
+	// The service holds the lock while the command reads the log
+	running, err := state.OpenFileStore(outputFile, state.Retention{}, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+	defer running.Close()
+	require.NoError(t, running.Record(state.Entry{Version: state.EntryVersion, IP: "192.168.1.1", Timestamp: "2025-07-01T12:00:00Z", Changed: true}))
+	require.NoError(t, running.Record(state.Entry{Version: state.EntryVersion, IP: "192.168.1.1", Timestamp: "2025-07-02T12:00:00Z", PreviousIP: "192.168.1.1"}))
+
+	var stdout, stderr bytes.Buffer
+	code := historyCommand([]string{"-changes", "-format", "csv"}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "2025-07-01T12:00:00Z,192.168.1.1")
+	assert.NotContains(t, stdout.String(), "2025-07-02")
+
+	stdout.Reset()
+	code = historyCommand([]string{"-since", "tomorrow"}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+}
+
+func TestHistoryCommand_File(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("OUTPUT_FILE", filepath.Join(t.TempDir(), "missing.log"))
+
+	// A log copied off the server, read without any configuration
+	logFile := filepath.Join(t.TempDir(), "copy.log")
+	store := state.NewFileStore(logFile)
+	require.NoError(t, store.Record(state.Entry{Version: state.EntryVersion, IP: "10.0.0.1", Timestamp: "2025-07-01T12:00:00Z", Changed: true}))
+
+	var stdout, stderr bytes.Buffer
+	code := historyCommand([]string{"-file", logFile, "-format", "csv"}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "2025-07-01T12:00:00Z,10.0.0.1")
+}
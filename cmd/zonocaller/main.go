@@ -2,58 +2,146 @@ package main
 
 import (
 	"context"
-	"log/slog"
+	"encoding/json"
+	"flag"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Drakx/ZonoCaller/internal/config"
 	"github.com/Drakx/ZonoCaller/internal/fetcher"
+	"github.com/Drakx/ZonoCaller/internal/health"
+	"github.com/Drakx/ZonoCaller/internal/history"
+	"github.com/Drakx/ZonoCaller/internal/logging"
+	"github.com/Drakx/ZonoCaller/internal/metrics"
 	"github.com/Drakx/ZonoCaller/internal/scheduler"
+	"github.com/Drakx/ZonoCaller/internal/server"
+	"github.com/Drakx/ZonoCaller/internal/state"
 )
 
+// commands maps subcommand names to their implementations.
+var commands = map[string]func(args []string) int{
+	"encrypt":     runEncrypt,
+	"decrypt":     runDecrypt,
+	"rekey":       runRekey,
+	"history":     runHistory,
+	"pause":       runPause,
+	"resume":      runResume,
+	"healthcheck": runHealthcheck,
+}
+
 func main() {
 
-	// Setup logging to stdout in JSON format
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level:     slog.LevelInfo,
-		AddSource: true,
-	}))
+	// Dispatch subcommands before parsing the service's flags
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	flag.Parse()
+
+	// Setup logging to stdout in JSON format, with secrets masked
+	logger := logging.New(os.Stdout)
 
 	// Load config
-	cfg, err := config.New()
+	cfg, err := config.Load(*configFile)
 	if err != nil {
 		logger.Error("Failed to load configuration", "error", err)
 		os.Exit(1)
 	}
 
-	// Initialize fetcher
-	f := fetcher.New(*cfg)
+	// Open the state store
+	store, err := state.Open(*cfg, logger)
+	if err != nil {
+		logger.Error("Failed to open state store", "error", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	// Initialize fetcher, reporting request latencies and retries
+	m := metrics.New()
+	f := fetcher.New(*cfg, fetcher.WithStore(store), fetcher.WithMetrics(m))
 
 	// Check for interrupt signals
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	// Start health check server in background
+	// Create the scheduler, with run outcomes tracked for health checks and
+	// metrics
+	monitor := health.New(*cfg)
+	s := scheduler.New(*cfg, f, logger,
+		scheduler.WithLastRun(store.LastRun),
+		scheduler.WithPauseStore(store),
+		scheduler.WithRunObserver(monitor.RecordRun),
+		scheduler.WithRunObserver(m.RecordRun),
+	)
+
+	// Bind the HTTP server before starting; failing to is fatal
+	srv, err := server.Listen(*cfg, newMux(*cfg, s, monitor, m, store), logger)
+	if err != nil {
+		logger.Error("Failed to start HTTP server", "error", err)
+		os.Exit(1)
+	}
+
+	// Serve until shutdown, and stop the scheduler if serving fails
+	served := make(chan error, 1)
 	go func() {
-		mux := http.NewServeMux()
-		mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("OK"))
-		})
-
-		if err := http.ListenAndServe(":8000", mux); err != nil {
-			logger.Error("Failed to start health server", "error", err)
-		}
+		err := srv.Serve(ctx)
+		cancel()
+		served <- err
 	}()
 
-	// Run scheduler
-	s := scheduler.New(*cfg, f, logger)
-	if err := s.Run(ctx); err != nil {
-		logger.Error("Scheduler failed", "error", err)
+	// Reload configuration on SIGHUP or config file change
+	r := &reloader{path: *configFile, started: cfg, current: cfg, fetcher: f, scheduler: s, health: monitor, logger: logger}
+	go r.run(ctx)
+
+	// Run now on SIGUSR1
+	go triggerOnSignal(ctx, s)
+
+	runErr := s.Run(ctx)
+
+	// Shut the server down once the scheduler stops, e.g. after RUN_ONCE
+	cancel()
+	if err := <-served; err != nil {
+		logger.Error("HTTP server failed", "error", err)
+		os.Exit(1)
+	}
+
+	if runErr != nil {
+		logger.Error("Scheduler failed", "error", runErr)
 		os.Exit(1)
 	}
 
-	logger.Info("Scheduler started", "schedule_time", cfg.ScheduleTime, "timezone", cfg.Timezone)
+	logger.Info("Scheduler stopped")
+}
+
+// newMux routes the HTTP endpoints. The admin endpoints, and the history,
+// which lists every public IP the host has had, require ADMIN_TOKEN.
+func newMux(cfg config.Config, s *scheduler.Scheduler, monitor *health.Monitor, m *metrics.Metrics, store state.Store) *http.ServeMux {
+
+	// The timezone is validated with the rest of the config
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/livez", health.LivezHandler())
+	mux.Handle("/readyz", health.ReadyzHandler(monitor, s))
+	mux.Handle("/health", health.Handler(monitor, s))
+	mux.Handle("/metrics", m.Handler())
+	mux.Handle("/history", scheduler.RequireToken(cfg.AdminToken, history.Handler(store, loc)))
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Status())
+	})
+	mux.Handle("/trigger", scheduler.RequireToken(cfg.AdminToken, scheduler.TriggerHandler(s)))
+	mux.Handle("/pause", scheduler.RequireToken(cfg.AdminToken, scheduler.PauseHandler(s)))
+	mux.Handle("/resume", scheduler.RequireToken(cfg.AdminToken, scheduler.ResumeHandler(s)))
+
+	return mux
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/Drakx/ZonoCaller/internal/health"
+	"github.com/Drakx/ZonoCaller/internal/metrics"
+	"github.com/Drakx/ZonoCaller/internal/scheduler"
+	"github.com/Drakx/ZonoCaller/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMux_History(t *testing.T) {
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "ip_log.log"))
+	require.NoError(t, store.Record(state.Entry{Version: state.EntryVersion, IP: "192.168.1.1", Timestamp: "2025-07-01T12:00:00Z", Changed: true}))
+
+	serve := func(cfg config.Config, authorization string) *httptest.ResponseRecorder {
+		s := scheduler.New(cfg, nil, slog.New(slog.DiscardHandler))
+		mux := newMux(cfg, s, health.New(cfg), metrics.New(), store)
+
+		req := httptest.NewRequest(http.MethodGet, "/history", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	cfg := config.Config{Timezone: "UTC", ScheduleTime: "23:59"}
+
+	// Without ADMIN_TOKEN the history is not served at all
+	rec := serve(cfg, "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "192.168.1.1")
+
+	cfg.AdminToken = "admin-token"
+	rec = serve(cfg, "Bearer wrong-token")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "192.168.1.1")
+
+	rec = serve(cfg, "Bearer admin-token")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Body.String(), "192.168.1.1")
+}
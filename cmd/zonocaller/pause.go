@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/Drakx/ZonoCaller/internal/state"
+)
+
+// runPause implements "zonocaller pause": it pauses runs of the running
+// service.
+func runPause(args []string) int {
+	return pauseCommand(args, os.Stdout, os.Stderr)
+}
+
+// runResume implements "zonocaller resume": it resumes runs of the running
+// service.
+func runResume(args []string) int {
+	return resumeCommand(args, os.Stdout, os.Stderr)
+}
+
+// pauseCommand asks the running service to pause runs. The service holds
+// the state store, so the pause goes through its HTTP API, authenticated
+// with the configured ADMIN_TOKEN.
+func pauseCommand(args []string, stdout, stderr io.Writer) int {
+
+	flags := flag.NewFlagSet("pause", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	client := adminFlags(flags)
+	reason := flags.String("reason", "", "why runs are paused, shown in status")
+	duration := flags.Duration("for", 0, "resume automatically after this long, e.g. 2h (default: until resumed)")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: zonocaller pause [-config FILE] [-addr URL] [-cacert FILE] [-insecure] [-reason TEXT] [-for DURATION]")
+		fmt.Fprintln(stderr, "Pauses scheduled and manual runs of the running service.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *duration < 0 {
+		fmt.Fprintln(stderr, "Error: -for must not be negative")
+		return 2
+	}
+
+	query := url.Values{}
+	if *reason != "" {
+		query.Set("reason", *reason)
+	}
+	if *duration > 0 {
+		query.Set("for", duration.String())
+	}
+
+	pause, err := client.post("/pause", query)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	if pause.Until != "" {
+		fmt.Fprintln(stdout, "Paused until", pause.Until)
+	} else {
+		fmt.Fprintln(stdout, "Paused until resumed")
+	}
+
+	return 0
+}
+
+// resumeCommand asks the running service to resume runs.
+func resumeCommand(args []string, stdout, stderr io.Writer) int {
+
+	flags := flag.NewFlagSet("resume", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	client := adminFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: zonocaller resume [-config FILE] [-addr URL] [-cacert FILE] [-insecure]")
+		fmt.Fprintln(stderr, "Resumes runs of the running service.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if _, err := client.post("/resume", nil); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	fmt.Fprintln(stdout, "Resumed")
+	return 0
+}
+
+// adminClient calls the admin endpoints of the running service.
+type adminClient struct {
+	configFile string
+	addr       string
+	caCert     string
+	insecure   bool
+}
+
+// adminFlags registers the flags that locate the running service.
+func adminFlags(flags *flag.FlagSet) *adminClient {
+
+	c := &adminClient{}
+	flags.StringVar(&c.configFile, "config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	flags.StringVar(&c.addr, "addr", "", "address of the running service, or unix:/path for a Unix socket (default: from HTTP_ADDR)")
+	flags.StringVar(&c.caCert, "cacert", "", "PEM file of the CA that signed the service's certificate, e.g. a self-signed HTTP_TLS_CERT_FILE")
+	flags.BoolVar(&c.insecure, "insecure", false, "skip verifying the service's certificate")
+	return c
+}
+
+// connect loads the client settings and returns them with an HTTP client
+// for the service and the service's base URL.
+func (c *adminClient) connect() (*config.Config, *http.Client, string, error) {
+
+	cfg, err := config.LoadClient(c.configFile)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	addr := c.addr
+	if addr == "" {
+		addr = serviceAddr(*cfg)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.insecure}
+	if c.caCert != "" {
+		pem, err := os.ReadFile(c.caCert)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, nil, "", fmt.Errorf("no certificates found in %s", c.caCert)
+		}
+	}
+	transport := &http.Transport{TLSClientConfig: tlsConfig}
+
+	// Reach a service listening on a Unix socket through its path
+	if socket, ok := strings.CutPrefix(addr, config.UnixAddrPrefix); ok {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		addr = "http://localhost"
+		if cfg.HTTPTLSCertFile != "" {
+			addr = "https://localhost"
+		}
+	}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: transport}
+
+	return cfg, client, strings.TrimSuffix(addr, "/"), nil
+}
+
+// post posts to an endpoint of the service, authenticated with the
+// configured ADMIN_TOKEN, and decodes the resulting paused state.
+func (c *adminClient) post(path string, query url.Values) (state.Pause, error) {
+
+	cfg, client, addr, err := c.connect()
+	if err != nil {
+		return state.Pause{}, err
+	}
+
+	endpoint := addr + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, nil)
+	if err != nil {
+		return state.Pause{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	if cfg.AdminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.AdminToken.Reveal())
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return state.Pause{}, fmt.Errorf("failed to reach the service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&body) == nil && body.Error != "" {
+			return state.Pause{}, fmt.Errorf("service responded %s: %s", resp.Status, body.Error)
+		}
+		return state.Pause{}, fmt.Errorf("service responded %s", resp.Status)
+	}
+
+	var pause state.Pause
+	if err := json.NewDecoder(resp.Body).Decode(&pause); err != nil {
+		return state.Pause{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return pause, nil
+}
+
+// serviceAddr returns the address the service listens on, as a URL on
+// localhost when it listens on all interfaces. Unix socket addresses are
+// returned as is. The scheme is https when HTTP_TLS_CERT_FILE is set.
+func serviceAddr(cfg config.Config) string {
+
+	if strings.HasPrefix(cfg.HTTPAddr, config.UnixAddrPrefix) {
+		return cfg.HTTPAddr
+	}
+
+	scheme := "http"
+	if cfg.HTTPTLSCertFile != "" {
+		scheme = "https"
+	}
+
+	host, port, err := net.SplitHostPort(cfg.HTTPAddr)
+	if err != nil {
+		return scheme + "://" + cfg.HTTPAddr
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
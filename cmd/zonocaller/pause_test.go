@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/pem"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/Drakx/ZonoCaller/internal/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseCommand(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("ADMIN_TOKEN", "admin-token")
+
+	cfg := config.Config{Timezone: "UTC", ScheduleTime: "23:59"}
+	s := scheduler.New(cfg, nil, slog.New(slog.DiscardHandler))
+
+	mux := http.NewServeMux()
+	mux.Handle("/pause", scheduler.RequireToken("admin-token", scheduler.PauseHandler(s)))
+	mux.Handle("/resume", scheduler.RequireToken("admin-token", scheduler.ResumeHandler(s)))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := pauseCommand([]string{"-addr", server.URL, "-reason", "router swap", "-for", "2h"}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Paused until")
+	status := s.Status()
+	assert.True(t, status.Paused)
+	assert.Equal(t, "router swap", status.PauseReason)
+
+	stdout.Reset()
+	code = resumeCommand([]string{"-addr", server.URL + "/"}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "Resumed\n", stdout.String())
+	assert.False(t, s.Status().Paused)
+
+	// Errors from the service are reported
+	stderr.Reset()
+	code = pauseCommand([]string{"-addr", server.URL + "/missing"}, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "404")
+
+	code = pauseCommand([]string{"-for", "-1h"}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+
+	// A wrong token is refused
+	os.Setenv("ADMIN_TOKEN", "wrong-token")
+	stderr.Reset()
+	code = pauseCommand([]string{"-addr", server.URL}, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "401")
+	assert.False(t, s.Status().Paused)
+}
+
+func TestPauseCommand_UnixSocket(t *testing.T) {
+	os.Clearenv()
+
+	cfg := config.Config{Timezone: "UTC", ScheduleTime: "23:59"}
+	s := scheduler.New(cfg, nil, slog.New(slog.DiscardHandler))
+
+	path := filepath.Join(t.TempDir(), "zonocaller.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(scheduler.PauseHandler(s))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := pauseCommand([]string{"-addr", "unix:" + path}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "Paused until resumed\n", stdout.String())
+	assert.True(t, s.Status().Paused)
+}
+
+func TestPauseCommand_TLS(t *testing.T) {
+	os.Clearenv()
+
+	cfg := config.Config{Timezone: "UTC", ScheduleTime: "23:59"}
+	s := scheduler.New(cfg, nil, slog.New(slog.DiscardHandler))
+	server := httptest.NewTLSServer(scheduler.ResumeHandler(s))
+	defer server.Close()
+
+	// The address comes from HTTP_ADDR, over https with HTTP_TLS_CERT_FILE
+	os.Setenv("HTTP_ADDR", server.Listener.Addr().String())
+	os.Setenv("HTTP_TLS_CERT_FILE", "/certs/tls.crt")
+
+	// The test server's certificate is self-signed
+	var stdout, stderr bytes.Buffer
+	code := resumeCommand(nil, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "certificate")
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+	stderr.Reset()
+	code = resumeCommand([]string{"-cacert", caCert}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+
+	stderr.Reset()
+	code = resumeCommand([]string{"-insecure"}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+}
+
+func TestServiceAddr(t *testing.T) {
+	tests := []struct {
+		addr     string
+		certFile string
+		expected string
+	}{
+		{":8000", "", "http://localhost:8000"},
+		{":8443", "/certs/tls.crt", "https://localhost:8443"},
+		{"0.0.0.0:8000", "", "http://localhost:8000"},
+		{"[::]:8000", "", "http://localhost:8000"},
+		{"127.0.0.1:9000", "", "http://127.0.0.1:9000"},
+		{"[::1]:9000", "", "http://[::1]:9000"},
+		{"dns.example.com:8443", "/certs/tls.crt", "https://dns.example.com:8443"},
+		{"unix:/app/data/zonocaller.sock", "", "unix:/app/data/zonocaller.sock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			cfg := config.Config{HTTPAddr: tt.addr, HTTPTLSCertFile: tt.certFile}
+			assert.Equal(t, tt.expected, serviceAddr(cfg))
+		})
+	}
+}
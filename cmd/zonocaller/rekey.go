@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+)
+
+// runRekey implements "zonocaller rekey": it re-encrypts secrets read from
+// stdin under a new key and reports which key each secret used.
+func runRekey(args []string) int {
+	return rekeyCommand(args, os.Stdin, os.Stdout, os.Stderr)
+}
+
+// rekeyCommand re-encrypts each secret on stdin, one per line, with the key
+// keyID and prints the results in the same order. Nothing is printed unless
+// every secret could be decrypted.
+func rekeyCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+
+	flags := flag.NewFlagSet("rekey", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	keyID := flags.String("key-id", os.Getenv("ZONOMI_ENCRYPTION_KEY_ID"), "ID of the key to re-encrypt with")
+	check := flags.Bool("check", false, "only report which key each secret uses")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: zonocaller rekey [-key-id ID] [-check] < secrets")
+		fmt.Fprintln(stderr, "Re-encrypts secrets (one per line) with the key ID from ZONOMI_ENCRYPTION_KEYS or ZONOMI_ENCRYPTION_KEY.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	keyring, err := config.LoadKeyring()
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	if _, ok := keyring.Keys[*keyID]; !ok && !*check {
+		fmt.Fprintf(stderr, "Error: no encryption key with ID %q\n", *keyID)
+		return 1
+	}
+
+	var results []string
+	failed := false
+	scanner := bufio.NewScanner(stdin)
+	for n := 1; scanner.Scan(); n++ {
+		encrypted := strings.TrimSpace(scanner.Text())
+		if encrypted == "" {
+			continue
+		}
+
+		name := fmt.Sprintf("secret %d", n)
+		version, recordedID, err := config.SecretFormat(encrypted)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", name, err)
+			failed = true
+			continue
+		}
+
+		plaintext, usedID, err := keyring.Decrypt(name, encrypted)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", name, err)
+			failed = true
+			continue
+		}
+
+		fmt.Fprintf(stderr, "%s: format v%d, key %s\n", name, version, describeKeyID(usedID))
+		if *check {
+			continue
+		}
+
+		// Secrets that already record the target key are left as they are
+		if version == 2 && recordedID != "" && recordedID == *keyID {
+			results = append(results, encrypted)
+			continue
+		}
+
+		rekeyed, err := keyring.Encrypt(*keyID, []byte(plaintext))
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", name, err)
+			failed = true
+			continue
+		}
+
+		// Keep the enc: prefix if the input had it
+		if strings.HasPrefix(encrypted, config.EncryptedPrefix) {
+			rekeyed = config.EncryptedPrefix + rekeyed
+		}
+
+		fmt.Fprintf(stderr, "%s: re-encrypted with key %s\n", name, describeKeyID(*keyID))
+		results = append(results, rekeyed)
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(stderr, "Error: failed to read secrets:", err)
+		return 1
+	}
+
+	if failed {
+		return 1
+	}
+
+	for _, result := range results {
+		fmt.Fprintln(stdout, result)
+	}
+
+	return 0
+}
+
+// describeKeyID formats a key ID for display.
+func describeKeyID(id string) string {
+
+	if id == "" {
+		return "(unnamed)"
+	}
+
+	return fmt.Sprintf("%q", id)
+}
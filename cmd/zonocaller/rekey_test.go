@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRekeyCommand(t *testing.T) {
+	os.Clearenv()
+
+	// Encrypt a secret with the old key
+	oldKeyring := config.Keyring{Primary: "old", Keys: map[string]string{"old": "old passphrase"}}
+	encrypted, err := oldKeyring.Encrypt("old", []byte("test-api-key"))
+	require.NoError(t, err)
+
+	// Rotate to the new key
+	os.Setenv("ZONOMI_ENCRYPTION_KEY_ID", "new")
+	os.Setenv("ZONOMI_ENCRYPTION_KEY", "new passphrase")
+	os.Setenv("ZONOMI_ENCRYPTION_KEYS", "old:old passphrase")
+
+	var stdout, stderr bytes.Buffer
+	code := rekeyCommand(nil, strings.NewReader(encrypted+"\n"), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stderr.String(), `secret 1: format v2, key "old"`)
+	assert.Contains(t, stderr.String(), `secret 1: re-encrypted with key "new"`)
+
+	// The result uses the new key only
+	rekeyed := strings.TrimSpace(stdout.String())
+	_, keyID, err := config.SecretFormat(rekeyed)
+	require.NoError(t, err)
+	assert.Equal(t, "new", keyID)
+
+	newKeyring := config.Keyring{Primary: "new", Keys: map[string]string{"new": "new passphrase"}}
+	plaintext, _, err := newKeyring.Decrypt("secret", rekeyed)
+	require.NoError(t, err)
+	assert.Equal(t, "test-api-key", plaintext)
+
+	// Checking reports the key without re-encrypting
+	stdout.Reset()
+	stderr.Reset()
+	code = rekeyCommand([]string{"-check"}, strings.NewReader(rekeyed+"\n"), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Empty(t, stdout.String())
+	assert.Contains(t, stderr.String(), `secret 1: format v2, key "new"`)
+}
+
+func TestRekeyCommand_UndecryptableSecret(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("ZONOMI_ENCRYPTION_KEY", strings.Repeat("k", 32))
+
+	var stdout, stderr bytes.Buffer
+	code := rekeyCommand(nil, strings.NewReader("not-base64!\n"), &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Empty(t, stdout.String(), "nothing is printed unless every secret was re-encrypted")
+	assert.Contains(t, stderr.String(), "secret 1:")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/Drakx/ZonoCaller/internal/fetcher"
+	"github.com/Drakx/ZonoCaller/internal/health"
+	"github.com/Drakx/ZonoCaller/internal/scheduler"
+)
+
+// reloader reloads the configuration and swaps it into the running fetcher
+// and scheduler.
+type reloader struct {
+	mu        sync.Mutex
+	path      string
+	started   *config.Config
+	current   *config.Config
+	fetcher   *fetcher.Fetcher
+	scheduler *scheduler.Scheduler
+	health    *health.Monitor
+	logger    *slog.Logger
+}
+
+// run reloads on SIGHUP and, when CONFIG_WATCH_INTERVAL is set, whenever the
+// config file changes. It returns when ctx is cancelled.
+func (r *reloader) run(ctx context.Context) {
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	if r.path != "" && r.current.ConfigWatchInterval > 0 {
+		go config.Watch(ctx, r.path, r.current.ConfigWatchInterval, func() {
+			r.reload("config file changed")
+		})
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.reload("SIGHUP")
+		}
+	}
+}
+
+// reload loads and validates the configuration again. An invalid
+// configuration is logged and reported by the health check, and the current
+// one is kept.
+func (r *reloader) reload(reason string) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.logger.Info("Reloading configuration", "reason", reason)
+
+	cfg, err := config.Load(r.path)
+	if err != nil {
+		r.logger.Error("Failed to reload configuration, keeping current configuration", "error", err)
+		r.health.ReloadFailed(err)
+		return
+	}
+
+	changes := config.Diff(r.current, cfg)
+	if len(changes) == 0 {
+		r.logger.Info("Configuration unchanged")
+		r.health.Reload(*r.current)
+		return
+	}
+
+	// Check everything before changing anything, so the fetcher and
+	// scheduler never run on different configurations
+	applySchedule, err := r.scheduler.PrepareReload(*cfg)
+	if err != nil {
+		r.logger.Error("Failed to apply configuration, keeping current configuration", "error", err)
+		r.health.ReloadFailed(err)
+		return
+	}
+
+	r.fetcher.Reload(*cfg)
+	applySchedule()
+	r.health.Reload(*cfg)
+
+	r.logger.Info("Configuration reloaded", "changes", changes)
+
+	// The service keeps using the startup values of restart-only settings,
+	// so warn on every reload until it is restarted
+	if restart := config.RestartRequired(r.started, cfg); len(restart) > 0 {
+		r.logger.Warn("Changed settings only take effect on restart, still using the values from startup", "settings", restart)
+	}
+	r.current = cfg
+}
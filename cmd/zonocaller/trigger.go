@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Drakx/ZonoCaller/internal/scheduler"
+)
+
+// triggerOnSignal runs the fetch job whenever SIGUSR1 is received. It
+// returns when ctx is cancelled.
+func triggerOnSignal(ctx context.Context, s *scheduler.Scheduler) {
+
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+	defer signal.Stop(usr1)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-usr1:
+			// The scheduler logs the outcome; don't block further signals
+			go s.Trigger(false, "SIGUSR1")
+		}
+	}
+}
@@ -1,173 +1,489 @@
 package config
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+)
+
+// Ways of sending the Zonomi API key, selected by ZONOMI_AUTH_MODE.
+const (
+	// AuthModeQuery sends the key as a query parameter on a GET request.
+	AuthModeQuery = "query"
+	// AuthModePost sends the key in a POST form body, keeping it out of URLs.
+	AuthModePost = "post"
+)
+
+// State backends, selected by STATE_BACKEND.
+const (
+	// StateBackendFile keeps state in the JSON-lines OUTPUT_FILE.
+	StateBackendFile = "file"
+	// StateBackendBolt keeps state in a bbolt database at STATE_PATH.
+	StateBackendBolt = "bolt"
+)
+
+// Default locations of the state store and the HTTP server, and the default
+// timezone.
+const (
+	defaultOutputFile = "/app/data/ip_log.log"
+	defaultStatePath  = "/app/data/state.db"
+	defaultHTTPAddr   = ":8000"
+	defaultTimezone   = "Europe/London"
+)
+
+// What to do when a run is due while another is in progress, selected by
+// OVERLAP_POLICY.
+const (
+	// OverlapSkip drops the new run.
+	OverlapSkip = "skip"
+	// OverlapQueue runs it once the current run finishes. At most one run
+	// waits; further runs are dropped.
+	OverlapQueue = "queue"
 )
 
 // Config holds the application configuration
 type Config struct {
-	APIURL              string
-	OutputFile          string
-	Timezone            string
-	ScheduleTime        string
-	ZonomiHosts         []string
-	ZonomiAPIKey        string
-	ZonomiAPIEncrypted  bool
-	ZonomiEncryptionKey string
-	MaxRetries          int
-	RunOnce             bool
-	ZonomiAPIURL        string
+	APIURL                string
+	OutputFile            string
+	StateBackend          string
+	StatePath             string
+	IPLogMaxAge           time.Duration
+	IPLogMaxEntries       int
+	IPLogMaxSize          int64
+	IPLogChangesOnly      bool
+	IPLogHeartbeat        time.Duration
+	Timezone              string
+	ScheduleTime          string
+	Schedule              string
+	RunOnStartup          bool
+	CatchUp               bool
+	RunTimeout            time.Duration
+	OverlapPolicy         string
+	ScheduleJitter        time.Duration
+	AdaptiveSchedule      bool
+	AdaptiveMinInterval   time.Duration
+	AdaptiveMaxInterval   time.Duration
+	NetworkWatch          bool
+	NetworkWatchDebounce  time.Duration
+	MaintenanceWindows    []string
+	HealthMaxFailures     int
+	HealthMaxAge          time.Duration
+	HTTPAddr              string
+	HTTPTLSCertFile       string
+	HTTPTLSKeyFile        string
+	HTTPReadTimeout       time.Duration
+	HTTPWriteTimeout      time.Duration
+	HTTPShutdownTimeout   time.Duration
+	AdminToken            Secret
+	ZonomiHosts           []string
+	ZonomiAPIKey          Secret
+	ZonomiAPIEncrypted    bool
+	ZonomiEncryptionKey   string
+	ZonomiEncryptionKeyID string
+	ZonomiEncryptionKeys  map[string]string
+	MaxRetries            int
+	RunOnce               bool
+	ZonomiAPIURL          string
+	ZonomiAuthMode        string
+	ConfigFile            string
+	ConfigWatchInterval   time.Duration
 }
 
-// New creates a new Config instance from environment variables.
+// New creates a new Config instance from the file named by CONFIG_FILE (if
+// any) and environment variables.
 func New() (*Config, error) {
+	return Load(getEnv("CONFIG_FILE", ""))
+}
+
+// Load creates a new Config instance from the YAML file at path and
+// environment variables. Environment variables override values from the
+// file, and an empty path skips the file entirely.
+func Load(path string) (*Config, error) {
 
 	cfg := &Config{
-		APIURL:       getEnv("API_URL", "https://api.ipify.org?format=json"),
-		OutputFile:   getEnv("OUTPUT_FILE", "/app/data/ip_log.log"),
-		MaxRetries:   getEnvInt("MAX_RETRIES", 3),
-		Timezone:     getEnv("TIMEZONE", "Europe/London"),
-		ScheduleTime: getEnv("SCHEDULE_TIME", "23:59"),
-		ZonomiAPIURL: getEnv("ZONOMI_API_URL", "https://zonomi.com/app/dns/dyndns.jsp"),
-		RunOnce:      getEnvBool("RUN_ONCE", false),
+		APIURL:               "https://api.ipify.org?format=json",
+		OutputFile:           defaultOutputFile,
+		StateBackend:         StateBackendFile,
+		StatePath:            defaultStatePath,
+		MaxRetries:           3,
+		Timezone:             defaultTimezone,
+		ScheduleTime:         "23:59",
+		RunTimeout:           2 * time.Minute,
+		OverlapPolicy:        OverlapSkip,
+		AdaptiveMinInterval:  5 * time.Minute,
+		AdaptiveMaxInterval:  time.Hour,
+		NetworkWatchDebounce: 5 * time.Second,
+		HealthMaxFailures:    3,
+		HTTPAddr:             defaultHTTPAddr,
+		HTTPReadTimeout:      10 * time.Second,
+		HTTPWriteTimeout:     30 * time.Second,
+		HTTPShutdownTimeout:  10 * time.Second,
+		ZonomiAPIURL:         "https://zonomi.com/app/dns/dyndns.jsp",
+		ZonomiAuthMode:       AuthModePost,
+		ConfigFile:           path,
+	}
+
+	// Apply the config file on top of the defaults
+	if path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// Environment variables take precedence over the file. Parse failures
+	// are collected so every problem is reported at once.
+	var errs []error
+	var err error
+	cfg.APIURL = getEnv("API_URL", cfg.APIURL)
+	cfg.OutputFile = getEnv("OUTPUT_FILE", cfg.OutputFile)
+	cfg.StateBackend = getEnv("STATE_BACKEND", cfg.StateBackend)
+	cfg.StatePath = getEnv("STATE_PATH", cfg.StatePath)
+	cfg.Timezone = getEnv("TIMEZONE", cfg.Timezone)
+	cfg.ScheduleTime = getEnv("SCHEDULE_TIME", cfg.ScheduleTime)
+	cfg.Schedule = getEnv("SCHEDULE", cfg.Schedule)
+	cfg.OverlapPolicy = getEnv("OVERLAP_POLICY", cfg.OverlapPolicy)
+	cfg.HTTPAddr = getEnv("HTTP_ADDR", cfg.HTTPAddr)
+	cfg.HTTPTLSCertFile = getEnv("HTTP_TLS_CERT_FILE", cfg.HTTPTLSCertFile)
+	cfg.HTTPTLSKeyFile = getEnv("HTTP_TLS_KEY_FILE", cfg.HTTPTLSKeyFile)
+	cfg.ZonomiAPIURL = getEnv("ZONOMI_API_URL", cfg.ZonomiAPIURL)
+	cfg.ZonomiAuthMode = getEnv("ZONOMI_AUTH_MODE", cfg.ZonomiAuthMode)
+
+	if cfg.MaxRetries, err = getEnvInt("MAX_RETRIES", cfg.MaxRetries); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.RunOnce, err = getEnvBool("RUN_ONCE", cfg.RunOnce); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.RunOnStartup, err = getEnvBool("RUN_ON_STARTUP", cfg.RunOnStartup); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.CatchUp, err = getEnvBool("CATCH_UP", cfg.CatchUp); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.RunTimeout, err = getEnvDuration("RUN_TIMEOUT", cfg.RunTimeout); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.ScheduleJitter, err = getEnvDuration("SCHEDULE_JITTER", cfg.ScheduleJitter); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Load the adaptive schedule settings
+	if cfg.AdaptiveSchedule, err = getEnvBool("ADAPTIVE_SCHEDULE", cfg.AdaptiveSchedule); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.AdaptiveMinInterval, err = getEnvDuration("ADAPTIVE_MIN_INTERVAL", cfg.AdaptiveMinInterval); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.AdaptiveMaxInterval, err = getEnvDuration("ADAPTIVE_MAX_INTERVAL", cfg.AdaptiveMaxInterval); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Load the network change watcher settings
+	if cfg.NetworkWatch, err = getEnvBool("NETWORK_WATCH", cfg.NetworkWatch); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.NetworkWatchDebounce, err = getEnvDuration("NETWORK_WATCH_DEBOUNCE", cfg.NetworkWatchDebounce); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Load the health check thresholds
+	if cfg.HealthMaxFailures, err = getEnvInt("HEALTH_MAX_FAILURES", cfg.HealthMaxFailures); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.HealthMaxAge, err = getEnvDuration("HEALTH_MAX_AGE", cfg.HealthMaxAge); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Load the HTTP server timeouts
+	if cfg.HTTPReadTimeout, err = getEnvDuration("HTTP_READ_TIMEOUT", cfg.HTTPReadTimeout); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.HTTPWriteTimeout, err = getEnvDuration("HTTP_WRITE_TIMEOUT", cfg.HTTPWriteTimeout); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.HTTPShutdownTimeout, err = getEnvDuration("HTTP_SHUTDOWN_TIMEOUT", cfg.HTTPShutdownTimeout); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Load the IP log retention settings
+	if cfg.IPLogMaxAge, err = getEnvDuration("IP_LOG_MAX_AGE", cfg.IPLogMaxAge); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.IPLogMaxEntries, err = getEnvInt("IP_LOG_MAX_ENTRIES", cfg.IPLogMaxEntries); err != nil {
+		errs = append(errs, err)
 	}
 
-	// Load ZONOMI_HOSTS
-	hosts, err := loadHosts()
+	maxSize, err := getEnvInt("IP_LOG_MAX_SIZE", int(cfg.IPLogMaxSize))
 	if err != nil {
-		return nil, err
+		errs = append(errs, err)
+	}
+	cfg.IPLogMaxSize = int64(maxSize)
+
+	if cfg.IPLogChangesOnly, err = getEnvBool("IP_LOG_CHANGES_ONLY", cfg.IPLogChangesOnly); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.IPLogHeartbeat, err = getEnvDuration("IP_LOG_HEARTBEAT", cfg.IPLogHeartbeat); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.ConfigWatchInterval, err = getEnvDuration("CONFIG_WATCH_INTERVAL", cfg.ConfigWatchInterval); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Load ZONOMI_HOSTS and MAINTENANCE_WINDOWS
+	cfg.ZonomiHosts = loadHosts(cfg.ZonomiHosts)
+	cfg.MaintenanceWindows = loadMaintenanceWindows(cfg.MaintenanceWindows)
+
+	// Load ZONOMI_API_ENCRYPTED, the encryption keys and ZONOMI_API_KEY
+	if cfg.ZonomiAPIEncrypted, err = getEnvBool("ZONOMI_API_ENCRYPTED", cfg.ZonomiAPIEncrypted); err != nil {
+		errs = append(errs, err)
 	}
 
-	cfg.ZonomiHosts = hosts
+	errs = append(errs, loadKeys(cfg)...)
 
-	// Load ZONOMI_API_ENCRYPTED and ZONOMI_ENCRYPTION_KEY
-	cfg.ZonomiAPIEncrypted = getEnvBool("ZONOMI_API_ENCRYPTED", false)
-	cfg.ZonomiEncryptionKey = getEnv("ZONOMI_ENCRYPTION_KEY", "")
+	apiKey, err := getSecret("ZONOMI_API_KEY", cfg.ZonomiAPIKey.Reveal())
+	if err != nil {
+		errs = append(errs, err)
+	}
+	cfg.ZonomiAPIKey = Secret(apiKey)
 
-	// Load and possibly decrypt ZONOMI_API_KEY
-	apiKey, err := loadAPIKey(cfg.ZonomiAPIEncrypted, cfg.ZonomiEncryptionKey)
+	// Load ADMIN_TOKEN
+	adminToken, err := getSecret("ADMIN_TOKEN", cfg.AdminToken.Reveal())
 	if err != nil {
-		return nil, err
+		errs = append(errs, err)
+	}
+	cfg.AdminToken = Secret(adminToken)
+
+	// Validate every field before decrypting anything
+	errs = append(errs, cfg.Validate())
+
+	// Decrypt secrets stored encrypted: values with the enc: prefix, and
+	// ZONOMI_API_KEY when ZONOMI_API_ENCRYPTED is true
+	errs = append(errs, cfg.decryptSecrets()...)
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
 	}
-	cfg.ZonomiAPIKey = apiKey
 
 	// Ensure output directory exists
 	if err := os.MkdirAll(filepath.Dir(cfg.OutputFile), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	if cfg.StateBackend == StateBackendBolt {
+		if err := os.MkdirAll(filepath.Dir(cfg.StatePath), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create state directory: %w", err)
+		}
+	}
+
 	return cfg, nil
 }
 
-// getEnv retrieves an environment variable or returns a default.
-func getEnv(key, defaultValue string) string {
+// LoadState reads only the settings that locate the state store, OUTPUT_FILE,
+// STATE_BACKEND and STATE_PATH, and the TIMEZONE its dates are in, from the
+// YAML file at path and environment variables. Nothing is validated or decrypted, so commands that only read
+// state work without the Zonomi settings and keys.
+func LoadState(path string) (*Config, error) {
 
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+	cfg := &Config{
+		OutputFile:   defaultOutputFile,
+		StateBackend: StateBackendFile,
+		StatePath:    defaultStatePath,
+		Timezone:     defaultTimezone,
+		ConfigFile:   path,
 	}
 
-	return defaultValue
+	if path != "" {
+		fc, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		setValue(&cfg.OutputFile, fc.OutputFile)
+		setValue(&cfg.StateBackend, fc.StateBackend)
+		setValue(&cfg.StatePath, fc.StatePath)
+		setValue(&cfg.Timezone, fc.Timezone)
+	}
+
+	cfg.OutputFile = getEnv("OUTPUT_FILE", cfg.OutputFile)
+	cfg.StateBackend = getEnv("STATE_BACKEND", cfg.StateBackend)
+	cfg.StatePath = getEnv("STATE_PATH", cfg.StatePath)
+	cfg.Timezone = getEnv("TIMEZONE", cfg.Timezone)
+
+	return cfg, nil
 }
 
-// getEnvInt retrieves an environment variable as an integer or returns a default.
-func getEnvInt(key string, defaultValue int) int {
+// LoadClient reads the settings that commands calling the running service
+// need, HTTP_ADDR, HTTP_TLS_CERT_FILE and ADMIN_TOKEN, from the YAML file at
+// path and environment variables. Other settings are not validated. An
+// encrypted token is decrypted with the configured keys.
+func LoadClient(path string) (*Config, error) {
+
+	cfg := &Config{HTTPAddr: defaultHTTPAddr, ConfigFile: path}
 
-	if value, exists := os.LookupEnv(key); exists {
-		var n int
-		if _, err := fmt.Sscanf(value, "%d", &n); err == nil {
-			return n
+	if path != "" {
+		fc, err := readFile(path)
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	return defaultValue
-}
+		setValue(&cfg.HTTPAddr, fc.HTTP.Addr)
+		setValue(&cfg.HTTPTLSCertFile, fc.HTTP.TLSCertFile)
+		setValue(&cfg.AdminToken, fc.HTTP.AdminToken)
+		setValue(&cfg.ZonomiEncryptionKey, fc.Zonomi.EncryptionKey)
+		setValue(&cfg.ZonomiEncryptionKeyID, fc.Zonomi.EncryptionKeyID)
+		if fc.Zonomi.EncryptionKeys != nil {
+			cfg.ZonomiEncryptionKeys = fc.Zonomi.EncryptionKeys
+		}
 
-// getEnvBool retrieves an environment variable as a boolean or returns a default.
-func getEnvBool(key string, defaultValue bool) bool {
+		if err := setSecretFromFile(&cfg.AdminToken, fc.HTTP.AdminToken, "http.admin_token", fc.HTTP.AdminTokenFile); err != nil {
+			return nil, err
+		}
 
-	if value, exists := os.LookupEnv(key); exists {
-		return value == "true"
+		if err := setSecretFromFile(&cfg.ZonomiEncryptionKey, fc.Zonomi.EncryptionKey, "zonomi.encryption_key", fc.Zonomi.EncryptionKeyFile); err != nil {
+			return nil, err
+		}
 	}
 
-	return defaultValue
-}
+	cfg.HTTPAddr = getEnv("HTTP_ADDR", cfg.HTTPAddr)
+	cfg.HTTPTLSCertFile = getEnv("HTTP_TLS_CERT_FILE", cfg.HTTPTLSCertFile)
 
-// loadHosts parses the ZONOMI_HOSTS environment variable into a slice of strings.
-func loadHosts() ([]string, error) {
+	errs := loadKeys(cfg)
 
-	hostsStr := getEnv("ZONOMI_HOSTS", "")
-	if hostsStr == "" {
-		return nil, fmt.Errorf("ZONOMI_HOSTS is required")
+	adminToken, err := getSecret("ADMIN_TOKEN", cfg.AdminToken.Reveal())
+	if err != nil {
+		errs = append(errs, err)
 	}
+	cfg.AdminToken = Secret(adminToken)
 
-	hosts := strings.Split(hostsStr, ",")
-	for i, host := range hosts {
-		hosts[i] = strings.TrimSpace(host)
+	if strings.HasPrefix(adminToken, EncryptedPrefix) && cfg.ZonomiEncryptionKey == "" && len(cfg.ZonomiEncryptionKeys) == 0 {
+		errs = append(errs, fmt.Errorf("ZONOMI_ENCRYPTION_KEY is required to decrypt ADMIN_TOKEN"))
 	}
 
-	if len(hosts) == 0 {
-		return nil, fmt.Errorf("ZONOMI_HOSTS is empty after parsing")
+	errs = append(errs, cfg.decryptSecrets()...)
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
 	}
 
-	return hosts, nil
+	return cfg, nil
+}
+
+// lookupEnv retrieves an environment variable. An empty variable counts as
+// unset, so blank ENV lines (as in the Dockerfile) don't override the config
+// file.
+func lookupEnv(key string) (string, bool) {
+
+	value := os.Getenv(key)
+	return value, value != ""
 }
 
-// decryptAPIKey decrypts an encrypted API key using AES-256-GCM.
-func decryptAPIKey(encryptedKey, encryptKey string) (string, error) {
+// getEnv retrieves an environment variable or returns a default.
+func getEnv(key, defaultValue string) string {
 
-	data, err := base64.StdEncoding.DecodeString(encryptedKey)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode ZONOMI_API_KEY: %w", err)
+	if value, exists := lookupEnv(key); exists {
+		return value
 	}
 
-	if len(encryptKey) != 32 {
-		return "", fmt.Errorf("ZONOMI_ENCRYPTION_KEY must be 32 bytes, got %d", len(encryptKey))
+	return defaultValue
+}
+
+// getEnvInt retrieves an environment variable as an integer or returns a default.
+// A value that is not an integer is an error.
+func getEnvInt(key string, defaultValue int) (int, error) {
+
+	if value, exists := lookupEnv(key); exists {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return defaultValue, fmt.Errorf("%s must be an integer, got %q", key, value)
+		}
+		return n, nil
 	}
 
-	block, err := aes.NewCipher([]byte(encryptKey))
-	if err != nil {
-		return "", fmt.Errorf("failed to create AES cipher: %w", err)
+	return defaultValue, nil
+}
+
+// getEnvBool retrieves an environment variable as a boolean or returns a default.
+// A value that is not a boolean (true/false, 1/0) is an error.
+func getEnvBool(key string, defaultValue bool) (bool, error) {
+
+	if value, exists := lookupEnv(key); exists {
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return defaultValue, fmt.Errorf("%s must be true or false, got %q", key, value)
+		}
+		return b, nil
 	}
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+	return defaultValue, nil
+}
+
+// getEnvDuration retrieves an environment variable as a duration (e.g., "30s")
+// or returns a default. A value that is not a duration is an error.
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+
+	if value, exists := lookupEnv(key); exists {
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return defaultValue, fmt.Errorf("%s must be a duration such as 30s or 5m, got %q", key, value)
+		}
+		return d, nil
 	}
 
-	nonceSize := gcm.NonceSize()
-	if len(data) < nonceSize {
-		return "", fmt.Errorf("invalid ciphertext: too short")
+	return defaultValue, nil
+}
+
+// loadHosts parses the ZONOMI_HOSTS environment variable into a slice of strings,
+// falling back to the hosts from the config file. Empty entries are kept so
+// that Validate can report them.
+func loadHosts(fileHosts []string) []string {
+
+	hostsStr := getEnv("ZONOMI_HOSTS", strings.Join(fileHosts, ","))
+	if hostsStr == "" {
+		return nil
 	}
 
-	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to decrypt ZONOMI_API_KEY: %w", err)
+	hosts := strings.Split(hostsStr, ",")
+	for i, host := range hosts {
+		hosts[i] = strings.TrimSpace(host)
 	}
 
-	return string(plaintext), nil
+	return hosts
 }
 
-// loadAPIKey loads and optionally decrypts the ZONOMI_API_KEY.
-func loadAPIKey(encrypted bool, encryptKey string) (string, error) {
+// loadMaintenanceWindows parses the semicolon-separated MAINTENANCE_WINDOWS
+// environment variable, falling back to the windows from the config file.
+// Semicolons separate entries because cron expressions contain commas.
+func loadMaintenanceWindows(fileWindows []string) []string {
 
-	apiKey := getEnv("ZONOMI_API_KEY", "")
-	if apiKey == "" {
-		return "", fmt.Errorf("ZONOMI_API_KEY is required")
+	value, exists := lookupEnv("MAINTENANCE_WINDOWS")
+	if !exists {
+		return fileWindows
 	}
 
-	if encrypted {
-		if encryptKey == "" {
-			return "", fmt.Errorf("ZONOMI_ENCRYPTION_KEY is required when ZONOMI_API_ENCRYPTED is true")
+	var windows []string
+	for _, window := range strings.Split(value, ";") {
+		if window = strings.TrimSpace(window); window != "" {
+			windows = append(windows, window)
 		}
-		return decryptAPIKey(apiKey, encryptKey)
 	}
 
-	return apiKey, nil
+	return windows
 }
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -35,14 +36,25 @@ func TestNewConfig_Defaults(t *testing.T) {
 	// Assert default values
 	assert.Equal(t, "https://api.ipify.org?format=json", cfg.APIURL)
 	assert.Equal(t, outputFile, cfg.OutputFile)
+	assert.Equal(t, StateBackendFile, cfg.StateBackend)
+	assert.Equal(t, "/app/data/state.db", cfg.StatePath)
 	assert.Equal(t, 3, cfg.MaxRetries)
 	assert.Equal(t, "Europe/London", cfg.Timezone)
 	assert.Equal(t, "23:59", cfg.ScheduleTime)
+	assert.Equal(t, 2*time.Minute, cfg.RunTimeout)
+	assert.Equal(t, OverlapSkip, cfg.OverlapPolicy)
+	assert.Equal(t, 3, cfg.HealthMaxFailures)
+	assert.Equal(t, ":8000", cfg.HTTPAddr)
+	assert.Equal(t, 10*time.Second, cfg.HTTPReadTimeout)
+	assert.Equal(t, 30*time.Second, cfg.HTTPWriteTimeout)
+	assert.Equal(t, 10*time.Second, cfg.HTTPShutdownTimeout)
+	assert.Equal(t, time.Duration(0), cfg.HealthMaxAge)
 	assert.Equal(t, []string{"example.com"}, cfg.ZonomiHosts)
-	assert.Equal(t, "test-api-key", cfg.ZonomiAPIKey)
+	assert.Equal(t, "test-api-key", cfg.ZonomiAPIKey.Reveal())
 	assert.Equal(t, "", cfg.ZonomiEncryptionKey)
 	assert.False(t, cfg.ZonomiAPIEncrypted)
 	assert.Equal(t, "https://zonomi.com/app/dns/dyndns.jsp", cfg.ZonomiAPIURL)
+	assert.Equal(t, AuthModePost, cfg.ZonomiAuthMode)
 
 	// Ensure output directory exists
 	_, err = os.Stat(filepath.Dir(cfg.OutputFile))
@@ -65,6 +77,8 @@ func TestNewConfig_EnvironmentOverrides(t *testing.T) {
 	os.Setenv("SCHEDULE_TIME", "12:00")
 	os.Setenv("ZONOMI_HOSTS", "test.host1, test.host2")
 	os.Setenv("ZONOMI_API_KEY", "custom-api-key")
+	os.Setenv("STATE_BACKEND", "bolt")
+	os.Setenv("STATE_PATH", filepath.Join(tempDir, "db", "state.db"))
 
 	// Load config
 	cfg, err := New()
@@ -73,11 +87,14 @@ func TestNewConfig_EnvironmentOverrides(t *testing.T) {
 	// Assert overridden values
 	assert.Equal(t, "https://test.api", cfg.APIURL)
 	assert.Equal(t, outputFile, cfg.OutputFile)
+	assert.Equal(t, StateBackendBolt, cfg.StateBackend)
+	assert.Equal(t, filepath.Join(tempDir, "db", "state.db"), cfg.StatePath)
+	assert.DirExists(t, filepath.Join(tempDir, "db"))
 	assert.Equal(t, 5, cfg.MaxRetries)
 	assert.Equal(t, "UTC", cfg.Timezone)
 	assert.Equal(t, "12:00", cfg.ScheduleTime)
 	assert.Equal(t, []string{"test.host1", "test.host2"}, cfg.ZonomiHosts)
-	assert.Equal(t, "custom-api-key", cfg.ZonomiAPIKey)
+	assert.Equal(t, "custom-api-key", cfg.ZonomiAPIKey.Reveal())
 	assert.Equal(t, "", cfg.ZonomiEncryptionKey)
 	assert.False(t, cfg.ZonomiAPIEncrypted)
 }
@@ -110,7 +127,7 @@ func TestNewConfig_EncryptedAPIKey(t *testing.T) {
 	require.NoError(t, err)
 
 	// Assert decrypted API key
-	assert.Equal(t, plainAPIKey, cfg.ZonomiAPIKey)
+	assert.Equal(t, plainAPIKey, cfg.ZonomiAPIKey.Reveal())
 	assert.True(t, cfg.ZonomiAPIEncrypted)
 	assert.Equal(t, encryptKey, cfg.ZonomiEncryptionKey)
 }
@@ -184,9 +201,9 @@ func TestNewConfig_InvalidTimezone(t *testing.T) {
 	os.Setenv("ZONOMI_API_KEY", "test-api-key")
 
 	// Load config
-	cfg, err := New()
-	require.NoError(t, err) // Timezone validation happens in scheduler, not config
-	assert.Equal(t, "Invalid/Timezone", cfg.Timezone)
+	_, err := New()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid TIMEZONE "Invalid/Timezone"`)
 }
 
 func TestNewConfig_EncryptedAPIKeyMissingEncryptKey(t *testing.T) {
@@ -228,7 +245,7 @@ func TestNewConfig_InvalidEncryptedAPIKey(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to decode ZONOMI_API_KEY")
 }
 
-// encrypt is a helper function for tests, mirroring the encryption logic in README.md
+// encrypt is a helper function for tests, producing the original raw-key format
 func encrypt(plaintext, key []byte) (string, error) {
 	c, err := aes.NewCipher(key)
 	if err != nil {
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig mirrors Config as it appears in a YAML config file. Pointer
+// fields distinguish a value that was left out from one set to its zero value.
+type fileConfig struct {
+	APIURL              *string            `yaml:"api_url"`
+	OutputFile          *string            `yaml:"output_file"`
+	StateBackend        *string            `yaml:"state_backend"`
+	StatePath           *string            `yaml:"state_path"`
+	MaxRetries          *int               `yaml:"max_retries"`
+	Timezone            *string            `yaml:"timezone"`
+	ScheduleTime        *string            `yaml:"schedule_time"`
+	Schedule            *string            `yaml:"schedule"`
+	RunOnce             *bool              `yaml:"run_once"`
+	RunOnStartup        *bool              `yaml:"run_on_startup"`
+	CatchUp             *bool              `yaml:"catch_up"`
+	RunTimeout          *time.Duration     `yaml:"run_timeout"`
+	OverlapPolicy       *string            `yaml:"overlap_policy"`
+	ScheduleJitter      *time.Duration     `yaml:"schedule_jitter"`
+	Adaptive            adaptiveConfig     `yaml:"adaptive"`
+	NetworkWatch        networkWatchConfig `yaml:"network_watch"`
+	MaintenanceWindows  []string           `yaml:"maintenance_windows"`
+	Health              healthFileConfig   `yaml:"health"`
+	HTTP                httpFileConfig     `yaml:"http"`
+	IPLog               ipLogFileConfig    `yaml:"ip_log"`
+	Zonomi              zonomiFileConfig   `yaml:"zonomi"`
+	ConfigWatchInterval *time.Duration     `yaml:"config_watch_interval"`
+}
+
+// ipLogFileConfig holds the ip_log section of a config file.
+type ipLogFileConfig struct {
+	MaxAge      *time.Duration `yaml:"max_age"`
+	MaxEntries  *int           `yaml:"max_entries"`
+	MaxSize     *int64         `yaml:"max_size"`
+	ChangesOnly *bool          `yaml:"changes_only"`
+	Heartbeat   *time.Duration `yaml:"heartbeat"`
+}
+
+// adaptiveConfig holds the adaptive section of a config file.
+type adaptiveConfig struct {
+	Enabled     *bool          `yaml:"enabled"`
+	MinInterval *time.Duration `yaml:"min_interval"`
+	MaxInterval *time.Duration `yaml:"max_interval"`
+}
+
+// networkWatchConfig holds the network_watch section of a config file.
+type networkWatchConfig struct {
+	Enabled  *bool          `yaml:"enabled"`
+	Debounce *time.Duration `yaml:"debounce"`
+}
+
+// healthFileConfig holds the health section of a config file.
+type healthFileConfig struct {
+	MaxFailures *int           `yaml:"max_failures"`
+	MaxAge      *time.Duration `yaml:"max_age"`
+}
+
+// httpFileConfig holds the http section of a config file.
+type httpFileConfig struct {
+	Addr            *string        `yaml:"addr"`
+	TLSCertFile     *string        `yaml:"tls_cert_file"`
+	TLSKeyFile      *string        `yaml:"tls_key_file"`
+	ReadTimeout     *time.Duration `yaml:"read_timeout"`
+	WriteTimeout    *time.Duration `yaml:"write_timeout"`
+	ShutdownTimeout *time.Duration `yaml:"shutdown_timeout"`
+	AdminToken      *Secret        `yaml:"admin_token"`
+	AdminTokenFile  *string        `yaml:"admin_token_file"`
+}
+
+// zonomiFileConfig holds the zonomi section of a config file.
+type zonomiFileConfig struct {
+	APIURL            *string           `yaml:"api_url"`
+	AuthMode          *string           `yaml:"auth_mode"`
+	Hosts             []string          `yaml:"hosts"`
+	APIKey            *Secret           `yaml:"api_key"`
+	APIKeyFile        *string           `yaml:"api_key_file"`
+	APIEncrypted      *bool             `yaml:"api_encrypted"`
+	EncryptionKey     *string           `yaml:"encryption_key"`
+	EncryptionKeyFile *string           `yaml:"encryption_key_file"`
+	EncryptionKeyID   *string           `yaml:"encryption_key_id"`
+	EncryptionKeys    map[string]string `yaml:"encryption_keys"`
+}
+
+// readFile parses the YAML config file at path.
+func readFile(path string) (*fileConfig, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	// Reject keys that do not map to a field so typos are not silently ignored
+	var fc fileConfig
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return &fc, nil
+}
+
+// loadFile reads the YAML config file at path and applies any values it sets to cfg.
+func loadFile(path string, cfg *Config) error {
+
+	fc, err := readFile(path)
+	if err != nil {
+		return err
+	}
+
+	setValue(&cfg.APIURL, fc.APIURL)
+	setValue(&cfg.OutputFile, fc.OutputFile)
+	setValue(&cfg.StateBackend, fc.StateBackend)
+	setValue(&cfg.StatePath, fc.StatePath)
+	setValue(&cfg.IPLogMaxAge, fc.IPLog.MaxAge)
+	setValue(&cfg.IPLogMaxEntries, fc.IPLog.MaxEntries)
+	setValue(&cfg.IPLogMaxSize, fc.IPLog.MaxSize)
+	setValue(&cfg.IPLogChangesOnly, fc.IPLog.ChangesOnly)
+	setValue(&cfg.IPLogHeartbeat, fc.IPLog.Heartbeat)
+	setValue(&cfg.Timezone, fc.Timezone)
+	setValue(&cfg.ScheduleTime, fc.ScheduleTime)
+	setValue(&cfg.Schedule, fc.Schedule)
+	setValue(&cfg.RunOnStartup, fc.RunOnStartup)
+	setValue(&cfg.CatchUp, fc.CatchUp)
+	setValue(&cfg.RunTimeout, fc.RunTimeout)
+	setValue(&cfg.OverlapPolicy, fc.OverlapPolicy)
+	setValue(&cfg.ScheduleJitter, fc.ScheduleJitter)
+	setValue(&cfg.AdaptiveSchedule, fc.Adaptive.Enabled)
+	setValue(&cfg.AdaptiveMinInterval, fc.Adaptive.MinInterval)
+	setValue(&cfg.AdaptiveMaxInterval, fc.Adaptive.MaxInterval)
+	setValue(&cfg.NetworkWatch, fc.NetworkWatch.Enabled)
+	setValue(&cfg.NetworkWatchDebounce, fc.NetworkWatch.Debounce)
+	setValue(&cfg.HealthMaxFailures, fc.Health.MaxFailures)
+	setValue(&cfg.HealthMaxAge, fc.Health.MaxAge)
+	setValue(&cfg.HTTPAddr, fc.HTTP.Addr)
+	setValue(&cfg.HTTPTLSCertFile, fc.HTTP.TLSCertFile)
+	setValue(&cfg.HTTPTLSKeyFile, fc.HTTP.TLSKeyFile)
+	setValue(&cfg.HTTPReadTimeout, fc.HTTP.ReadTimeout)
+	setValue(&cfg.HTTPWriteTimeout, fc.HTTP.WriteTimeout)
+	setValue(&cfg.HTTPShutdownTimeout, fc.HTTP.ShutdownTimeout)
+	setValue(&cfg.AdminToken, fc.HTTP.AdminToken)
+	setValue(&cfg.ZonomiAPIURL, fc.Zonomi.APIURL)
+	setValue(&cfg.ZonomiAuthMode, fc.Zonomi.AuthMode)
+	setValue(&cfg.ZonomiAPIKey, fc.Zonomi.APIKey)
+	setValue(&cfg.ZonomiEncryptionKey, fc.Zonomi.EncryptionKey)
+	setValue(&cfg.ZonomiEncryptionKeyID, fc.Zonomi.EncryptionKeyID)
+
+	// Secrets may also be read from separate files
+	if err := setSecretFromFile(&cfg.ZonomiAPIKey, fc.Zonomi.APIKey, "zonomi.api_key", fc.Zonomi.APIKeyFile); err != nil {
+		return err
+	}
+
+	if err := setSecretFromFile(&cfg.ZonomiEncryptionKey, fc.Zonomi.EncryptionKey, "zonomi.encryption_key", fc.Zonomi.EncryptionKeyFile); err != nil {
+		return err
+	}
+
+	if err := setSecretFromFile(&cfg.AdminToken, fc.HTTP.AdminToken, "http.admin_token", fc.HTTP.AdminTokenFile); err != nil {
+		return err
+	}
+
+	if fc.MaxRetries != nil {
+		cfg.MaxRetries = *fc.MaxRetries
+	}
+
+	if fc.RunOnce != nil {
+		cfg.RunOnce = *fc.RunOnce
+	}
+
+	if fc.ConfigWatchInterval != nil {
+		cfg.ConfigWatchInterval = *fc.ConfigWatchInterval
+	}
+
+	if fc.Zonomi.APIEncrypted != nil {
+		cfg.ZonomiAPIEncrypted = *fc.Zonomi.APIEncrypted
+	}
+
+	if fc.Zonomi.EncryptionKeys != nil {
+		cfg.ZonomiEncryptionKeys = fc.Zonomi.EncryptionKeys
+	}
+
+	if fc.Zonomi.Hosts != nil {
+		cfg.ZonomiHosts = fc.Zonomi.Hosts
+	}
+
+	if fc.MaintenanceWindows != nil {
+		cfg.MaintenanceWindows = fc.MaintenanceWindows
+	}
+
+	return nil
+}
+
+// setSecretFromFile reads the secret named by the <name>_file key into dst.
+// Setting both the value and the file is an error.
+func setSecretFromFile[T ~string](dst *T, value *T, name string, path *string) error {
+
+	if path == nil {
+		return nil
+	}
+
+	if value != nil {
+		return fmt.Errorf("only one of %s and %s_file may be set in the config file", name, name)
+	}
+
+	secret, err := readSecretFile(name+"_file", *path)
+	if err != nil {
+		return err
+	}
+
+	*dst = T(secret)
+
+	return nil
+}
+
+// setValue copies src into dst when the file set it.
+func setValue[T any](dst *T, src *T) {
+
+	if src != nil {
+		*dst = *src
+	}
+}
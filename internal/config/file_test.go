@@ -0,0 +1,252 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfigFile writes contents to a config file in a temporary directory.
+func writeConfigFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	return path
+}
+
+func TestLoad_File(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	outputFile := filepath.Join(t.TempDir(), "ip_log.txt")
+	path := writeConfigFile(t, `
+api_url: https://file.api
+output_file: `+outputFile+`
+max_retries: 7
+timezone: UTC
+schedule_time: "06:30"
+ip_log:
+  max_age: 720h
+  max_entries: 1000
+  max_size: 1048576
+  changes_only: true
+  heartbeat: 24h
+maintenance_windows:
+  - 0 2 * * 0 2h
+zonomi:
+  api_url: https://file.zonomi
+  auth_mode: query
+  hosts:
+    - a.example.com
+    - b.example.com
+  api_key: file-api-key
+`)
+
+	// Load config
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	// Assert values from the file
+	assert.Equal(t, "https://file.api", cfg.APIURL)
+	assert.Equal(t, outputFile, cfg.OutputFile)
+	assert.Equal(t, 7, cfg.MaxRetries)
+	assert.Equal(t, "UTC", cfg.Timezone)
+	assert.Equal(t, "06:30", cfg.ScheduleTime)
+	assert.Equal(t, 720*time.Hour, cfg.IPLogMaxAge)
+	assert.Equal(t, 1000, cfg.IPLogMaxEntries)
+	assert.Equal(t, int64(1048576), cfg.IPLogMaxSize)
+	assert.True(t, cfg.IPLogChangesOnly)
+	assert.Equal(t, 24*time.Hour, cfg.IPLogHeartbeat)
+	assert.Equal(t, []string{"0 2 * * 0 2h"}, cfg.MaintenanceWindows)
+	assert.Equal(t, "https://file.zonomi", cfg.ZonomiAPIURL)
+	assert.Equal(t, AuthModeQuery, cfg.ZonomiAuthMode)
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, cfg.ZonomiHosts)
+	assert.Equal(t, "file-api-key", cfg.ZonomiAPIKey.Reveal())
+	assert.Equal(t, path, cfg.ConfigFile)
+}
+
+func TestLoad_EnvironmentOverridesFile(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	path := writeConfigFile(t, `
+output_file: `+filepath.Join(t.TempDir(), "ip_log.txt")+`
+max_retries: 7
+zonomi:
+  hosts: [file.example.com]
+  api_key: file-api-key
+`)
+
+	// Set environment variables
+	os.Setenv("MAX_RETRIES", "2")
+	os.Setenv("ZONOMI_HOSTS", "env.example.com")
+
+	// Load config
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	// Assert environment wins, file fills the rest
+	assert.Equal(t, 2, cfg.MaxRetries)
+	assert.Equal(t, []string{"env.example.com"}, cfg.ZonomiHosts)
+	assert.Equal(t, "file-api-key", cfg.ZonomiAPIKey.Reveal())
+	assert.Equal(t, "Europe/London", cfg.Timezone)
+}
+
+func TestLoad_EmptyEnvironmentKeepsFile(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	path := writeConfigFile(t, `
+output_file: `+filepath.Join(t.TempDir(), "ip_log.txt")+`
+zonomi:
+  hosts: [file.example.com]
+  api_key: file-api-key
+`)
+
+	// The Dockerfile declares these with empty values
+	os.Setenv("ZONOMI_HOSTS", "")
+	os.Setenv("ZONOMI_API_KEY", "")
+	os.Setenv("ZONOMI_ENCRYPTION_KEY", "")
+
+	// Load config
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file.example.com"}, cfg.ZonomiHosts)
+	assert.Equal(t, "file-api-key", cfg.ZonomiAPIKey.Reveal())
+}
+
+func TestLoad_ConfigFileFromEnvironment(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	path := writeConfigFile(t, `
+output_file: `+filepath.Join(t.TempDir(), "ip_log.txt")+`
+zonomi:
+  hosts: [file.example.com]
+  api_key: file-api-key
+`)
+	os.Setenv("CONFIG_FILE", path)
+
+	// Load config
+	cfg, err := New()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file.example.com"}, cfg.ZonomiHosts)
+}
+
+func TestLoadState(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	// Neither the missing key file nor the absent hosts stop the state
+	// settings from loading
+	path := writeConfigFile(t, `
+state_backend: bolt
+state_path: /data/state.db
+timezone: America/New_York
+zonomi:
+  api_key_file: /nonexistent/api_key
+`)
+	os.Setenv("OUTPUT_FILE", "/data/ip_log.log")
+
+	cfg, err := LoadState(path)
+	require.NoError(t, err)
+	assert.Equal(t, StateBackendBolt, cfg.StateBackend)
+	assert.Equal(t, "/data/state.db", cfg.StatePath)
+	assert.Equal(t, "/data/ip_log.log", cfg.OutputFile)
+	assert.Equal(t, "America/New_York", cfg.Timezone)
+
+	_, err = LoadState(writeConfigFile(t, "unknown: true\n"))
+	assert.Error(t, err)
+}
+
+func TestLoadClient(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	// The Zonomi settings are not needed
+	path := writeConfigFile(t, `
+http:
+  admin_token_file: `+writeSecretFile(t, "file-admin-token\n", 0400)+`
+`)
+
+	cfg, err := LoadClient(path)
+	require.NoError(t, err)
+	assert.Equal(t, "file-admin-token", cfg.AdminToken.Reveal())
+	assert.Equal(t, ":8000", cfg.HTTPAddr)
+
+	os.Setenv("HTTP_ADDR", "127.0.0.1:9000")
+	os.Setenv("HTTP_TLS_CERT_FILE", "/certs/tls.crt")
+	cfg, err = LoadClient(path)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:9000", cfg.HTTPAddr)
+	assert.Equal(t, "/certs/tls.crt", cfg.HTTPTLSCertFile)
+
+	// An encrypted token is decrypted with the configured key
+	encrypted, err := encryptSecret([]byte("env-admin-token"), "", "passphrase", testKDFParams)
+	require.NoError(t, err)
+	os.Setenv("ADMIN_TOKEN", EncryptedPrefix+encrypted)
+	os.Setenv("ZONOMI_ENCRYPTION_KEY", "passphrase")
+
+	cfg, err = LoadClient("")
+	require.NoError(t, err)
+	assert.Equal(t, "env-admin-token", cfg.AdminToken.Reveal())
+
+	os.Unsetenv("ZONOMI_ENCRYPTION_KEY")
+	_, err = LoadClient("")
+	assert.Error(t, err)
+}
+
+func TestLoad_FileErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		contents    string
+		expectedErr string
+	}{
+		{
+			name:        "Unknown key",
+			contents:    "api_url: https://file.api\nscheduletime: daily\n",
+			expectedErr: "line 2: field scheduletime not found",
+		},
+		{
+			name:        "Unknown nested key",
+			contents:    "zonomi:\n  hosts: [a.example.com]\n  apikey: typo\n",
+			expectedErr: "line 3: field apikey not found",
+		},
+		{
+			name:        "Wrong type",
+			contents:    "max_retries: lots\n",
+			expectedErr: "line 1: cannot unmarshal",
+		},
+		{
+			name:        "Invalid YAML",
+			contents:    "zonomi:\n\thosts: [a.example.com]\n",
+			expectedErr: "line 2: found character that cannot start any token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Clear environment variables
+			os.Clearenv()
+
+			// Load config
+			_, err := Load(writeConfigFile(t, tt.contents))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "failed to parse config file")
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	// Load config
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to open config file")
+}
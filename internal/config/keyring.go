@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Keyring holds the encryption keys available for decrypting secrets, by key
+// ID. The primary key is used for encryption; during a rotation the old keys
+// stay in the keyring so existing secrets can still be decrypted.
+type Keyring struct {
+	Primary string
+	Keys    map[string]string
+}
+
+// Keyring returns the keyring made up of ZONOMI_ENCRYPTION_KEY (under the ID
+// ZONOMI_ENCRYPTION_KEY_ID) and the keys from ZONOMI_ENCRYPTION_KEYS.
+func (c *Config) Keyring() Keyring {
+
+	keys := maps.Clone(c.ZonomiEncryptionKeys)
+	if keys == nil {
+		keys = make(map[string]string)
+	}
+
+	if c.ZonomiEncryptionKey != "" {
+		keys[c.ZonomiEncryptionKeyID] = c.ZonomiEncryptionKey
+	}
+
+	return Keyring{Primary: c.ZonomiEncryptionKeyID, Keys: keys}
+}
+
+// LoadKeyring reads the keyring from ZONOMI_ENCRYPTION_KEY_ID,
+// ZONOMI_ENCRYPTION_KEY and ZONOMI_ENCRYPTION_KEYS (and their _FILE variants).
+func LoadKeyring() (Keyring, error) {
+
+	var cfg Config
+	errs := loadKeys(&cfg)
+	errs = append(errs, validateKeys(&cfg)...)
+
+	return cfg.Keyring(), errors.Join(errs...)
+}
+
+// Encrypt encrypts plaintext into a version 2 secret with the key keyID,
+// recording the ID in the secret's header.
+func (k Keyring) Encrypt(keyID string, plaintext []byte) (string, error) {
+
+	key, ok := k.Keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("no encryption key with ID %q", keyID)
+	}
+
+	return encryptSecret(plaintext, keyID, key, defaultKDFParams)
+}
+
+// Decrypt decrypts a secret in either format and returns the plaintext and the
+// ID of the key that decrypted it. Secrets that name their key ID are
+// decrypted with that key only; others are tried against the primary key
+// first and then every other key. name identifies the secret in error messages.
+func (k Keyring) Decrypt(name, encoded string) (string, string, error) {
+
+	secret, err := parseSecret(name, encoded)
+	if err != nil {
+		return "", "", err
+	}
+
+	if secret.keyID != "" {
+		key, ok := k.Keys[secret.keyID]
+		if !ok {
+			return "", "", fmt.Errorf("failed to decrypt %s: no encryption key with ID %q", name, secret.keyID)
+		}
+
+		plaintext, err := secret.open(key)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to decrypt %s with key %q: %w", name, secret.keyID, err)
+		}
+
+		return string(plaintext), secret.keyID, nil
+	}
+
+	ids := k.candidates()
+	if len(ids) == 0 {
+		return "", "", fmt.Errorf("failed to decrypt %s: no encryption keys configured", name)
+	}
+
+	var errs []error
+	for _, id := range ids {
+		plaintext, err := secret.open(k.Keys[id])
+		if err == nil {
+			return string(plaintext), id, nil
+		}
+		errs = append(errs, err)
+	}
+
+	if len(ids) == 1 {
+		return "", "", fmt.Errorf("failed to decrypt %s: %w", name, errs[0])
+	}
+
+	return "", "", fmt.Errorf("failed to decrypt %s: none of the %d configured keys match", name, len(ids))
+}
+
+// candidates returns the key IDs to try for a secret without a key ID, the
+// primary key first.
+func (k Keyring) candidates() []string {
+
+	ids := slices.Sorted(maps.Keys(k.Keys))
+	if i := slices.Index(ids, k.Primary); i > 0 {
+		ids = append([]string{k.Primary}, slices.Delete(ids, i, i+1)...)
+	}
+
+	return ids
+}
+
+// loadKeys reads ZONOMI_ENCRYPTION_KEY_ID, ZONOMI_ENCRYPTION_KEY and
+// ZONOMI_ENCRYPTION_KEYS (and their _FILE variants) into cfg, on top of any
+// values already set.
+func loadKeys(cfg *Config) []error {
+
+	var errs []error
+	var err error
+	cfg.ZonomiEncryptionKeyID = getEnv("ZONOMI_ENCRYPTION_KEY_ID", cfg.ZonomiEncryptionKeyID)
+
+	if cfg.ZonomiEncryptionKey, err = getSecret("ZONOMI_ENCRYPTION_KEY", cfg.ZonomiEncryptionKey); err != nil {
+		errs = append(errs, err)
+	}
+
+	keys, err := getSecret("ZONOMI_ENCRYPTION_KEYS", "")
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if keys != "" {
+		parsed, err := parseKeys(keys)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		cfg.ZonomiEncryptionKeys = parsed
+	}
+
+	return errs
+}
+
+// parseKeys parses ZONOMI_ENCRYPTION_KEYS: "id:key" entries separated by
+// commas or newlines.
+func parseKeys(value string) (map[string]string, error) {
+
+	keys := make(map[string]string)
+	entries := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' })
+	for i, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, key, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || key == "" {
+			return nil, fmt.Errorf("ZONOMI_ENCRYPTION_KEYS entry %d must have the form id:key", i+1)
+		}
+
+		if _, exists := keys[id]; exists {
+			return nil, fmt.Errorf("ZONOMI_ENCRYPTION_KEYS has duplicate key ID %q", id)
+		}
+
+		keys[id] = key
+	}
+
+	return keys, nil
+}
+
+// validateKeys checks the key IDs in the keyring fields of cfg.
+func validateKeys(cfg *Config) []error {
+
+	var errs []error
+	if cfg.ZonomiEncryptionKeyID != "" {
+		if err := validateKeyID(cfg.ZonomiEncryptionKeyID); err != nil {
+			errs = append(errs, fmt.Errorf("invalid ZONOMI_ENCRYPTION_KEY_ID: %w", err))
+		}
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(cfg.ZonomiEncryptionKeys)) {
+		if err := validateKeyID(id); err != nil {
+			errs = append(errs, fmt.Errorf("invalid key ID in ZONOMI_ENCRYPTION_KEYS: %w", err))
+		}
+	}
+
+	// ZONOMI_ENCRYPTION_KEY may repeat an entry but not contradict it
+	if key, ok := cfg.ZonomiEncryptionKeys[cfg.ZonomiEncryptionKeyID]; ok &&
+		cfg.ZonomiEncryptionKey != "" && key != cfg.ZonomiEncryptionKey {
+		errs = append(errs, fmt.Errorf("ZONOMI_ENCRYPTION_KEY differs from the key with ID %q in ZONOMI_ENCRYPTION_KEYS", cfg.ZonomiEncryptionKeyID))
+	}
+
+	return errs
+}
+
+// validateKeyID checks that id is 1-64 letters, digits, '.', '_' or '-'.
+func validateKeyID(id string) error {
+
+	if id == "" || len(id) > 64 {
+		return fmt.Errorf("key ID must be 1-64 characters, got %q", id)
+	}
+
+	for _, r := range id {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '.' && r != '_' && r != '-' {
+			return fmt.Errorf("key ID %q contains invalid character %q", id, r)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring_DecryptByKeyID(t *testing.T) {
+	keyring := Keyring{Primary: "new", Keys: map[string]string{"old": "old passphrase", "new": "new passphrase"}}
+
+	// A secret naming its key is decrypted with that key
+	encoded, err := encryptSecret([]byte("test-api-key"), "old", "old passphrase", testKDFParams)
+	require.NoError(t, err)
+	assert.Contains(t, encoded, ",kid=old$")
+
+	plaintext, keyID, err := keyring.Decrypt("ZONOMI_API_KEY", encoded)
+	require.NoError(t, err)
+	assert.Equal(t, "test-api-key", plaintext)
+	assert.Equal(t, "old", keyID)
+}
+
+func TestKeyring_DecryptWithoutKeyID(t *testing.T) {
+	keyring := Keyring{Primary: "new", Keys: map[string]string{
+		"old": strings.Repeat("o", 32),
+		"new": strings.Repeat("n", 32),
+	}}
+
+	// Original-format secrets are tried against every key
+	encoded, err := encrypt([]byte("test-api-key"), []byte(strings.Repeat("o", 32)))
+	require.NoError(t, err)
+
+	plaintext, keyID, err := keyring.Decrypt("ZONOMI_API_KEY", encoded)
+	require.NoError(t, err)
+	assert.Equal(t, "test-api-key", plaintext)
+	assert.Equal(t, "old", keyID)
+}
+
+func TestKeyring_DecryptErrors(t *testing.T) {
+	unknown, err := encryptSecret([]byte("test-api-key"), "retired", "retired passphrase", testKDFParams)
+	require.NoError(t, err)
+
+	unnamed, err := encryptSecret([]byte("test-api-key"), "", "lost passphrase", testKDFParams)
+	require.NoError(t, err)
+
+	keyring := Keyring{Keys: map[string]string{"a": "passphrase a", "b": "passphrase b"}}
+
+	_, _, err = keyring.Decrypt("ZONOMI_API_KEY", unknown)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `no encryption key with ID "retired"`)
+
+	_, _, err = keyring.Decrypt("ZONOMI_API_KEY", unnamed)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "none of the 2 configured keys match")
+
+	_, _, err = Keyring{}.Decrypt("ZONOMI_API_KEY", unnamed)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no encryption keys configured")
+}
+
+func TestKeyring_Encrypt(t *testing.T) {
+	keyring := Keyring{Primary: "new", Keys: map[string]string{"new": "new passphrase"}}
+
+	encoded, err := keyring.Encrypt("new", []byte("test-api-key"))
+	require.NoError(t, err)
+
+	version, keyID, err := SecretFormat(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+	assert.Equal(t, "new", keyID)
+
+	_, err = keyring.Encrypt("missing", []byte("test-api-key"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `no encryption key with ID "missing"`)
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := parseKeys("old:first key, new:second:key\nnewest:third")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"old": "first key", "new": "second:key", "newest": "third"}, keys)
+
+	_, err = parseKeys("old:a,old:b")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `duplicate key ID "old"`)
+
+	_, err = parseKeys("missing-separator")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "entry 1 must have the form id:key")
+}
+
+func TestNewConfig_KeyRotation(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	// The API key is still encrypted with the old key
+	encryptedAPIKey, err := encryptSecret([]byte("test-api-key"), "2025", "old passphrase", testKDFParams)
+	require.NoError(t, err)
+
+	os.Setenv("OUTPUT_FILE", filepath.Join(t.TempDir(), "ip_log.txt"))
+	os.Setenv("ZONOMI_HOSTS", "example.com")
+	os.Setenv("ZONOMI_API_KEY", encryptedAPIKey)
+	os.Setenv("ZONOMI_API_ENCRYPTED", "true")
+	os.Setenv("ZONOMI_ENCRYPTION_KEY_ID", "2026")
+	os.Setenv("ZONOMI_ENCRYPTION_KEY", "new passphrase")
+	os.Setenv("ZONOMI_ENCRYPTION_KEYS", "2025:old passphrase")
+
+	// Load config
+	cfg, err := New()
+	require.NoError(t, err)
+	assert.Equal(t, "test-api-key", cfg.ZonomiAPIKey.Reveal())
+	assert.Equal(t, Keyring{Primary: "2026", Keys: map[string]string{
+		"2025": "old passphrase",
+		"2026": "new passphrase",
+	}}, cfg.Keyring())
+}
+
+func TestNewConfig_InvalidKeyIDs(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	os.Setenv("OUTPUT_FILE", filepath.Join(t.TempDir(), "ip_log.txt"))
+	os.Setenv("ZONOMI_HOSTS", "example.com")
+	os.Setenv("ZONOMI_API_KEY", "test-api-key")
+	os.Setenv("ZONOMI_ENCRYPTION_KEY_ID", "bad id")
+	os.Setenv("ZONOMI_ENCRYPTION_KEYS", "ok:key,bad$id:key")
+
+	// Load config
+	_, err := New()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid ZONOMI_ENCRYPTION_KEY_ID: key ID "bad id" contains invalid character ' '`)
+	assert.Contains(t, err.Error(), `invalid key ID in ZONOMI_ENCRYPTION_KEYS: key ID "bad$id" contains invalid character '$'`)
+}
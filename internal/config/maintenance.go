@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// MaintenanceWindow is a period during which runs are skipped: either a
+// recurring window starting on a cron schedule and lasting Duration, or a
+// one-off range from Start to End.
+type MaintenanceWindow struct {
+	Cron     string
+	Duration time.Duration
+	Start    time.Time
+	End      time.Time
+
+	schedule cron.Schedule
+}
+
+// ParseMaintenanceWindow parses a MAINTENANCE_WINDOWS entry: a cron
+// expression followed by a duration (e.g. "0 2 * * 0 2h" for Sundays from
+// 02:00 to 04:00), or an RFC 3339 range (e.g.
+// "2025-09-01T00:00:00Z/2025-09-02T06:00:00Z").
+func ParseMaintenanceWindow(value string) (MaintenanceWindow, error) {
+
+	value = strings.TrimSpace(value)
+
+	// One-off ranges are the only form containing a slash
+	if start, end, ok := strings.Cut(value, "/"); ok {
+		startTime, err := time.Parse(time.RFC3339, strings.TrimSpace(start))
+		if err != nil {
+			return MaintenanceWindow{}, fmt.Errorf("invalid maintenance window %q: start must be RFC 3339", value)
+		}
+
+		endTime, err := time.Parse(time.RFC3339, strings.TrimSpace(end))
+		if err != nil {
+			return MaintenanceWindow{}, fmt.Errorf("invalid maintenance window %q: end must be RFC 3339", value)
+		}
+
+		if !endTime.After(startTime) {
+			return MaintenanceWindow{}, fmt.Errorf("invalid maintenance window %q: end must be after start", value)
+		}
+
+		return MaintenanceWindow{Start: startTime, End: endTime}, nil
+	}
+
+	// The duration is the last field, the cron expression the rest
+	i := strings.LastIndexByte(value, ' ')
+	if i < 0 {
+		return MaintenanceWindow{}, fmt.Errorf("invalid maintenance window %q, expected a cron expression and duration or a start/end range", value)
+	}
+
+	duration, err := time.ParseDuration(value[i+1:])
+	if err != nil || duration <= 0 {
+		return MaintenanceWindow{}, fmt.Errorf("invalid maintenance window %q: %q is not a positive duration", value, value[i+1:])
+	}
+
+	expr := strings.TrimSpace(value[:i])
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return MaintenanceWindow{}, fmt.Errorf("invalid maintenance window %q: %w", value, err)
+	}
+
+	return MaintenanceWindow{Cron: expr, Duration: duration, schedule: schedule}, nil
+}
+
+// Contains reports whether t falls within the window. Recurring windows
+// start at their cron times in loc.
+func (w MaintenanceWindow) Contains(t time.Time, loc *time.Location) bool {
+
+	if w.schedule == nil {
+		return !t.Before(w.Start) && t.Before(w.End)
+	}
+
+	// The first start after t-Duration is the only one whose window can hold t
+	start := w.schedule.Next(t.In(loc).Add(-w.Duration))
+	return !start.After(t)
+}
+
+// String describes the window for logs and status.
+func (w MaintenanceWindow) String() string {
+
+	if w.schedule == nil {
+		return w.Start.Format(time.RFC3339) + "/" + w.End.Format(time.RFC3339)
+	}
+
+	return w.Cron + " " + w.Duration.String()
+}
+
+// ParseMaintenanceWindows parses every entry of MAINTENANCE_WINDOWS.
+func (c *Config) ParseMaintenanceWindows() ([]MaintenanceWindow, error) {
+
+	windows := make([]MaintenanceWindow, 0, len(c.MaintenanceWindows))
+	for _, value := range c.MaintenanceWindows {
+		window, err := ParseMaintenanceWindow(value)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+
+	return windows, nil
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceWindow_Contains(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+
+	tests := []struct {
+		value    string
+		at       time.Time
+		expected bool
+	}{
+		// Sundays 02:00-04:00 London time; 2025-08-31 is a Sunday
+		{"0 2 * * 0 2h", time.Date(2025, 8, 31, 1, 59, 0, 0, loc), false},
+		{"0 2 * * 0 2h", time.Date(2025, 8, 31, 2, 0, 0, 0, loc), true},
+		{"0 2 * * 0 2h", time.Date(2025, 8, 31, 3, 59, 0, 0, loc), true},
+		{"0 2 * * 0 2h", time.Date(2025, 8, 31, 4, 0, 0, 0, loc), false},
+		{"0 2 * * 0 2h", time.Date(2025, 9, 1, 2, 30, 0, 0, loc), false},
+		// Windows spanning midnight
+		{"30 23 * * * 1h", time.Date(2025, 9, 1, 0, 15, 0, 0, loc), true},
+		{"30 23 * * * 1h", time.Date(2025, 9, 1, 0, 30, 0, 0, loc), false},
+		// One-off ranges
+		{"2025-09-01T00:00:00Z/2025-09-02T06:00:00Z", time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2025-09-01T00:00:00Z/2025-09-02T06:00:00Z", time.Date(2025, 9, 2, 6, 0, 0, 0, time.UTC), false},
+		{"2025-09-01T00:00:00Z/2025-09-02T06:00:00Z", time.Date(2025, 8, 31, 23, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value+" "+tt.at.String(), func(t *testing.T) {
+			window, err := ParseMaintenanceWindow(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, window.Contains(tt.at, loc))
+		})
+	}
+}
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	window, err := ParseMaintenanceWindow(" 0 2 * * 0 90m ")
+	require.NoError(t, err)
+	assert.Equal(t, "0 2 * * 0", window.Cron)
+	assert.Equal(t, 90*time.Minute, window.Duration)
+	assert.Equal(t, "0 2 * * 0 1h30m0s", window.String())
+
+	window, err = ParseMaintenanceWindow("2025-09-01T00:00:00+01:00/2025-09-02T06:00:00+01:00")
+	require.NoError(t, err)
+	assert.True(t, window.Start.Equal(time.Date(2025, 8, 31, 23, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "2025-09-01T00:00:00+01:00/2025-09-02T06:00:00+01:00", window.String())
+}
+
+func TestParseMaintenanceWindow_Invalid(t *testing.T) {
+	tests := []struct {
+		value       string
+		expectedErr string
+	}{
+		{"0 2 * * 0", "is not a positive duration"},
+		{"0 2 * * 0 -1h", "is not a positive duration"},
+		{"2h", "expected a cron expression and duration"},
+		{"0 2 * * 2h", "expected exactly 5 fields"},
+		{"2025-09-01/2025-09-02", "start must be RFC 3339"},
+		{"2025-09-01T00:00:00Z/tomorrow", "end must be RFC 3339"},
+		{"2025-09-02T00:00:00Z/2025-09-01T00:00:00Z", "end must be after start"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			_, err := ParseMaintenanceWindow(tt.value)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func TestLoad_MaintenanceWindows(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	os.Setenv("OUTPUT_FILE", filepath.Join(t.TempDir(), "ip_log.txt"))
+	os.Setenv("ZONOMI_HOSTS", "example.com")
+	os.Setenv("ZONOMI_API_KEY", "test-api-key")
+	os.Setenv("MAINTENANCE_WINDOWS", "0 2 * * 0 2h; 0 12 1,15 * * 30m;")
+
+	cfg, err := Load("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0 2 * * 0 2h", "0 12 1,15 * * 30m"}, cfg.MaintenanceWindows)
+
+	windows, err := cfg.ParseMaintenanceWindows()
+	require.NoError(t, err)
+	assert.Len(t, windows, 2)
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+)
+
+// restartOnly lists the settings that are read once at startup, so
+// changing them has no effect until the service restarts.
+var restartOnly = []string{
+	"RUN_ONCE", "RUN_ON_STARTUP", "CATCH_UP", "NETWORK_WATCH", "NETWORK_WATCH_DEBOUNCE", "CONFIG_WATCH_INTERVAL",
+	"HTTP_ADDR", "HTTP_TLS_CERT_FILE", "HTTP_TLS_KEY_FILE", "HTTP_READ_TIMEOUT", "HTTP_WRITE_TIMEOUT", "HTTP_SHUTDOWN_TIMEOUT",
+	"ADMIN_TOKEN", "OUTPUT_FILE", "STATE_BACKEND", "STATE_PATH", "IP_LOG_MAX_AGE", "IP_LOG_MAX_ENTRIES", "IP_LOG_MAX_SIZE",
+}
+
+// Diff describes the fields that differ between old and new. Secret values
+// are never included, only the fact that they changed.
+func Diff(old, new *Config) []string {
+
+	changes, _ := diff(old, new)
+	return changes
+}
+
+// RestartRequired returns the names of the settings that differ between old
+// and new but only take effect on restart.
+func RestartRequired(old, new *Config) []string {
+
+	_, names := diff(old, new)
+	return slices.DeleteFunc(names, func(name string) bool {
+		return !slices.Contains(restartOnly, name)
+	})
+}
+
+// diff describes the fields that differ between old and new, and returns
+// their names.
+func diff(old, new *Config) ([]string, []string) {
+
+	var changes, names []string
+	changed := func(name string, oldValue, newValue any) {
+		changes = append(changes, fmt.Sprintf("%s: %v -> %v", name, oldValue, newValue))
+		names = append(names, name)
+	}
+	secretChanged := func(name string) {
+		changes = append(changes, name+" changed")
+		names = append(names, name)
+	}
+
+	if old.APIURL != new.APIURL {
+		changed("API_URL", old.APIURL, new.APIURL)
+	}
+
+	if old.OutputFile != new.OutputFile {
+		changed("OUTPUT_FILE", old.OutputFile, new.OutputFile)
+	}
+
+	if old.StateBackend != new.StateBackend {
+		changed("STATE_BACKEND", old.StateBackend, new.StateBackend)
+	}
+
+	if old.StatePath != new.StatePath {
+		changed("STATE_PATH", old.StatePath, new.StatePath)
+	}
+
+	if old.IPLogMaxAge != new.IPLogMaxAge {
+		changed("IP_LOG_MAX_AGE", old.IPLogMaxAge, new.IPLogMaxAge)
+	}
+
+	if old.IPLogMaxEntries != new.IPLogMaxEntries {
+		changed("IP_LOG_MAX_ENTRIES", old.IPLogMaxEntries, new.IPLogMaxEntries)
+	}
+
+	if old.IPLogMaxSize != new.IPLogMaxSize {
+		changed("IP_LOG_MAX_SIZE", old.IPLogMaxSize, new.IPLogMaxSize)
+	}
+
+	if old.IPLogChangesOnly != new.IPLogChangesOnly {
+		changed("IP_LOG_CHANGES_ONLY", old.IPLogChangesOnly, new.IPLogChangesOnly)
+	}
+
+	if old.IPLogHeartbeat != new.IPLogHeartbeat {
+		changed("IP_LOG_HEARTBEAT", old.IPLogHeartbeat, new.IPLogHeartbeat)
+	}
+
+	if old.MaxRetries != new.MaxRetries {
+		changed("MAX_RETRIES", old.MaxRetries, new.MaxRetries)
+	}
+
+	if old.Timezone != new.Timezone {
+		changed("TIMEZONE", old.Timezone, new.Timezone)
+	}
+
+	if old.ScheduleTime != new.ScheduleTime {
+		changed("SCHEDULE_TIME", old.ScheduleTime, new.ScheduleTime)
+	}
+
+	if old.Schedule != new.Schedule {
+		changed("SCHEDULE", old.Schedule, new.Schedule)
+	}
+
+	if old.RunOnce != new.RunOnce {
+		changed("RUN_ONCE", old.RunOnce, new.RunOnce)
+	}
+
+	if old.RunOnStartup != new.RunOnStartup {
+		changed("RUN_ON_STARTUP", old.RunOnStartup, new.RunOnStartup)
+	}
+
+	if old.CatchUp != new.CatchUp {
+		changed("CATCH_UP", old.CatchUp, new.CatchUp)
+	}
+
+	if old.RunTimeout != new.RunTimeout {
+		changed("RUN_TIMEOUT", old.RunTimeout, new.RunTimeout)
+	}
+
+	if old.OverlapPolicy != new.OverlapPolicy {
+		changed("OVERLAP_POLICY", old.OverlapPolicy, new.OverlapPolicy)
+	}
+
+	if old.ScheduleJitter != new.ScheduleJitter {
+		changed("SCHEDULE_JITTER", old.ScheduleJitter, new.ScheduleJitter)
+	}
+
+	if old.AdaptiveSchedule != new.AdaptiveSchedule {
+		changed("ADAPTIVE_SCHEDULE", old.AdaptiveSchedule, new.AdaptiveSchedule)
+	}
+
+	if old.AdaptiveMinInterval != new.AdaptiveMinInterval {
+		changed("ADAPTIVE_MIN_INTERVAL", old.AdaptiveMinInterval, new.AdaptiveMinInterval)
+	}
+
+	if old.AdaptiveMaxInterval != new.AdaptiveMaxInterval {
+		changed("ADAPTIVE_MAX_INTERVAL", old.AdaptiveMaxInterval, new.AdaptiveMaxInterval)
+	}
+
+	if old.NetworkWatch != new.NetworkWatch {
+		changed("NETWORK_WATCH", old.NetworkWatch, new.NetworkWatch)
+	}
+
+	if old.NetworkWatchDebounce != new.NetworkWatchDebounce {
+		changed("NETWORK_WATCH_DEBOUNCE", old.NetworkWatchDebounce, new.NetworkWatchDebounce)
+	}
+
+	if old.HealthMaxFailures != new.HealthMaxFailures {
+		changed("HEALTH_MAX_FAILURES", old.HealthMaxFailures, new.HealthMaxFailures)
+	}
+
+	if old.HealthMaxAge != new.HealthMaxAge {
+		changed("HEALTH_MAX_AGE", old.HealthMaxAge, new.HealthMaxAge)
+	}
+
+	if old.HTTPAddr != new.HTTPAddr {
+		changed("HTTP_ADDR", old.HTTPAddr, new.HTTPAddr)
+	}
+
+	if old.HTTPTLSCertFile != new.HTTPTLSCertFile {
+		changed("HTTP_TLS_CERT_FILE", old.HTTPTLSCertFile, new.HTTPTLSCertFile)
+	}
+
+	if old.HTTPTLSKeyFile != new.HTTPTLSKeyFile {
+		changed("HTTP_TLS_KEY_FILE", old.HTTPTLSKeyFile, new.HTTPTLSKeyFile)
+	}
+
+	if old.HTTPReadTimeout != new.HTTPReadTimeout {
+		changed("HTTP_READ_TIMEOUT", old.HTTPReadTimeout, new.HTTPReadTimeout)
+	}
+
+	if old.HTTPWriteTimeout != new.HTTPWriteTimeout {
+		changed("HTTP_WRITE_TIMEOUT", old.HTTPWriteTimeout, new.HTTPWriteTimeout)
+	}
+
+	if old.HTTPShutdownTimeout != new.HTTPShutdownTimeout {
+		changed("HTTP_SHUTDOWN_TIMEOUT", old.HTTPShutdownTimeout, new.HTTPShutdownTimeout)
+	}
+
+	if old.AdminToken != new.AdminToken {
+		secretChanged("ADMIN_TOKEN")
+	}
+
+	if !slices.Equal(old.MaintenanceWindows, new.MaintenanceWindows) {
+		changed("MAINTENANCE_WINDOWS", old.MaintenanceWindows, new.MaintenanceWindows)
+	}
+
+	if !slices.Equal(old.ZonomiHosts, new.ZonomiHosts) {
+		changed("ZONOMI_HOSTS", old.ZonomiHosts, new.ZonomiHosts)
+	}
+
+	if old.ZonomiAPIURL != new.ZonomiAPIURL {
+		changed("ZONOMI_API_URL", old.ZonomiAPIURL, new.ZonomiAPIURL)
+	}
+
+	if old.ZonomiAuthMode != new.ZonomiAuthMode {
+		changed("ZONOMI_AUTH_MODE", old.ZonomiAuthMode, new.ZonomiAuthMode)
+	}
+
+	if old.ZonomiAPIEncrypted != new.ZonomiAPIEncrypted {
+		changed("ZONOMI_API_ENCRYPTED", old.ZonomiAPIEncrypted, new.ZonomiAPIEncrypted)
+	}
+
+	if old.ZonomiAPIKey != new.ZonomiAPIKey {
+		secretChanged("ZONOMI_API_KEY")
+	}
+
+	if old.ZonomiEncryptionKey != new.ZonomiEncryptionKey {
+		secretChanged("ZONOMI_ENCRYPTION_KEY")
+	}
+
+	if old.ZonomiEncryptionKeyID != new.ZonomiEncryptionKeyID {
+		changed("ZONOMI_ENCRYPTION_KEY_ID", old.ZonomiEncryptionKeyID, new.ZonomiEncryptionKeyID)
+	}
+
+	if !maps.Equal(old.ZonomiEncryptionKeys, new.ZonomiEncryptionKeys) {
+		secretChanged("ZONOMI_ENCRYPTION_KEYS")
+	}
+
+	if old.ConfigWatchInterval != new.ConfigWatchInterval {
+		changed("CONFIG_WATCH_INTERVAL", old.ConfigWatchInterval, new.ConfigWatchInterval)
+	}
+
+	return changes, names
+}
+
+// Watch polls the file at path every interval and calls onChange whenever
+// its modification time or size changes. It returns when ctx is cancelled.
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func()) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				// Keep the last known state while the file is missing or
+				// being replaced
+				continue
+			}
+
+			if last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() {
+				last = info
+				onChange()
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	old := validConfig()
+	new := validConfig()
+	new.ScheduleTime = "06:00"
+	new.ZonomiHosts = []string{"example.com"}
+	new.ZonomiAPIKey = "rotated-api-key"
+
+	changes := Diff(&old, &new)
+	assert.Equal(t, []string{
+		"SCHEDULE_TIME: 23:59 -> 06:00",
+		"ZONOMI_HOSTS: [example.com *.example.com host-1.example.com.] -> [example.com]",
+		"ZONOMI_API_KEY changed",
+	}, changes)
+
+	// Secrets never appear in the diff
+	for _, change := range changes {
+		assert.NotContains(t, change, "rotated-api-key")
+	}
+}
+
+func TestDiff_Unchanged(t *testing.T) {
+	old := validConfig()
+	new := validConfig()
+	assert.Empty(t, Diff(&old, &new))
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := validConfig()
+	new := validConfig()
+	new.ScheduleTime = "06:00"
+	new.HTTPAddr = ":9000"
+	new.AdminToken = "rotated-admin-token"
+	new.RunOnce = true
+
+	// Only settings read at startup are named, without their values
+	assert.Equal(t, []string{"RUN_ONCE", "HTTP_ADDR", "ADMIN_TOKEN"}, RestartRequired(&old, &new))
+	assert.Contains(t, Diff(&old, &new), "RUN_ONCE: false -> true")
+	assert.Empty(t, RestartRequired(&old, &old))
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("max_retries: 1\n"), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	go Watch(ctx, path, 10*time.Millisecond, func() {
+		changed <- struct{}{}
+	})
+
+	// Give the watcher time to record the initial state
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.WriteFile(path, []byte("max_retries: 10\n"), 0600))
+
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch should report the file change")
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// MinScheduleInterval is the shortest accepted SCHEDULE interval.
+const MinScheduleInterval = time.Minute
+
+// Schedule is a parsed SCHEDULE value. Exactly one of Cron, Interval and
+// Times is set.
+type Schedule struct {
+	// Cron is a standard five-field cron expression or a descriptor such as
+	// "@hourly"
+	Cron string
+	// Interval runs the job at a fixed interval
+	Interval time.Duration
+	// Times runs the job daily at each time of day
+	Times []TimeOfDay
+}
+
+// TimeOfDay is an hour and minute in the scheduler's timezone.
+type TimeOfDay struct {
+	Hour   int
+	Minute int
+}
+
+// String describes the schedule for logs and status.
+func (s Schedule) String() string {
+
+	switch {
+	case s.Cron != "":
+		return "cron " + s.Cron
+	case s.Interval > 0:
+		return "every " + s.Interval.String()
+	default:
+		times := make([]string, len(s.Times))
+		for i, at := range s.Times {
+			times[i] = fmt.Sprintf("%02d:%02d", at.Hour, at.Minute)
+		}
+		return "daily at " + strings.Join(times, ", ")
+	}
+}
+
+// ParseSchedule parses a SCHEDULE value: a comma-separated list of daily
+// times (e.g. "06:00,18:00"), a Go duration interval (e.g. "5m"), or a cron
+// expression (e.g. "*/15 * * * *" or "@hourly").
+func ParseSchedule(value string) (Schedule, error) {
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Schedule{}, fmt.Errorf("SCHEDULE must not be empty")
+	}
+
+	// Daily times are the only form containing a colon
+	if strings.Contains(value, ":") {
+		var times []TimeOfDay
+		for _, part := range strings.Split(value, ",") {
+			hour, minute, err := parseTimeOfDay("SCHEDULE", strings.TrimSpace(part))
+			if err != nil {
+				return Schedule{}, err
+			}
+			times = append(times, TimeOfDay{Hour: hour, Minute: minute})
+		}
+		return Schedule{Times: times}, nil
+	}
+
+	if interval, err := time.ParseDuration(value); err == nil {
+		if interval < MinScheduleInterval {
+			return Schedule{}, fmt.Errorf("SCHEDULE interval must be at least %s, got %s", MinScheduleInterval, interval)
+		}
+		return Schedule{Interval: interval}, nil
+	}
+
+	if _, err := cron.ParseStandard(value); err != nil {
+		return Schedule{}, fmt.Errorf("invalid SCHEDULE %q, expected daily times (HH:MM,...), an interval (e.g. 5m) or a cron expression: %w", value, err)
+	}
+
+	return Schedule{Cron: value}, nil
+}
+
+// Next returns the first scheduled time after t, in t's location. Intervals
+// are counted from t.
+func (s Schedule) Next(t time.Time) time.Time {
+
+	switch {
+	case s.Cron != "":
+		parsed, err := cron.ParseStandard(s.Cron)
+		if err != nil {
+			return time.Time{}
+		}
+		return parsed.Next(t)
+	case s.Interval > 0:
+		return t.Add(s.Interval)
+	default:
+		var next time.Time
+		for _, at := range s.Times {
+			candidate := time.Date(t.Year(), t.Month(), t.Day(), at.Hour, at.Minute, 0, 0, t.Location())
+			if !candidate.After(t) {
+				candidate = candidate.AddDate(0, 0, 1)
+			}
+			if next.IsZero() || candidate.Before(next) {
+				next = candidate
+			}
+		}
+		return next
+	}
+}
+
+// JobSchedule returns the schedule to run the fetch job on: SCHEDULE if set,
+// otherwise daily at SCHEDULE_TIME.
+func (c *Config) JobSchedule() (Schedule, error) {
+
+	if c.Schedule != "" {
+		return ParseSchedule(c.Schedule)
+	}
+
+	hour, minute, err := ParseScheduleTime(c.ScheduleTime)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	return Schedule{Times: []TimeOfDay{{Hour: hour, Minute: minute}}}, nil
+}
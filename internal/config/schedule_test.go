@@ -0,0 +1,92 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected Schedule
+		str      string
+	}{
+		{"06:00", Schedule{Times: []TimeOfDay{{6, 0}}}, "daily at 06:00"},
+		{"06:00, 18:30", Schedule{Times: []TimeOfDay{{6, 0}, {18, 30}}}, "daily at 06:00, 18:30"},
+		{"5m", Schedule{Interval: 5 * time.Minute}, "every 5m0s"},
+		{"*/15 * * * *", Schedule{Cron: "*/15 * * * *"}, "cron */15 * * * *"},
+		{"@hourly", Schedule{Cron: "@hourly"}, "cron @hourly"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, schedule)
+			assert.Equal(t, tt.str, schedule.String())
+		})
+	}
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	tests := []struct {
+		value       string
+		expectedErr string
+	}{
+		{"", "SCHEDULE must not be empty"},
+		{"06:00,25:00", "invalid SCHEDULE: hour must be 0-23"},
+		{"6am", "invalid SCHEDULE \"6am\""},
+		{"10s", "SCHEDULE interval must be at least 1m0s"},
+		{"* * *", "invalid SCHEDULE \"* * *\""},
+		{"61 * * * *", "invalid SCHEDULE \"61 * * * *\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			_, err := ParseSchedule(tt.value)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func TestJobSchedule(t *testing.T) {
+	cfg := validConfig()
+
+	// SCHEDULE_TIME is used when SCHEDULE is unset
+	schedule, err := cfg.JobSchedule()
+	require.NoError(t, err)
+	assert.Equal(t, Schedule{Times: []TimeOfDay{{23, 59}}}, schedule)
+
+	// SCHEDULE takes precedence
+	cfg.Schedule = "30m"
+	schedule, err = cfg.JobSchedule()
+	require.NoError(t, err)
+	assert.Equal(t, Schedule{Interval: 30 * time.Minute}, schedule)
+}
+
+func TestScheduleNext(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+	at := time.Date(2025, 8, 30, 12, 0, 0, 0, loc)
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		expected time.Time
+	}{
+		{"later today", Schedule{Times: []TimeOfDay{{6, 0}, {18, 0}}}, time.Date(2025, 8, 30, 18, 0, 0, 0, loc)},
+		{"tomorrow", Schedule{Times: []TimeOfDay{{6, 0}, {12, 0}}}, time.Date(2025, 8, 31, 6, 0, 0, 0, loc)},
+		{"interval", Schedule{Interval: 90 * time.Minute}, at.Add(90 * time.Minute)},
+		{"cron", Schedule{Cron: "30 * * * *"}, time.Date(2025, 8, 30, 12, 30, 0, 0, loc)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.True(t, tt.expected.Equal(tt.schedule.Next(at)), "got %s", tt.schedule.Next(at))
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// secretPrefixV2 marks a version 2 encrypted secret. Version 2 secrets have
+// the form
+//
+//	$zc2$argon2id$m=<memory KiB>,t=<iterations>,p=<threads>[,kid=<key ID>]$<salt>$<nonce+ciphertext>
+//
+// with the salt and ciphertext in unpadded base64. The AES-256-GCM key is
+// derived from a passphrase with Argon2id, and the header up to the salt is
+// authenticated along with the ciphertext. The optional key ID names the
+// Keyring entry the secret was encrypted with. Secrets without the prefix use
+// the original format: base64 nonce+ciphertext under a raw 32-byte key.
+const secretPrefixV2 = "$zc2$"
+
+// kdfParams holds the Argon2id parameters for a version 2 secret.
+type kdfParams struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// defaultKDFParams follows the RFC 9106 recommendation for memory-constrained
+// environments.
+var defaultKDFParams = kdfParams{memory: 64 * 1024, time: 3, threads: 4}
+
+// Limits on KDF parameters read from a secret, so that a tampered header
+// cannot make startup exhaust memory or CPU.
+const (
+	maxKDFMemory = 1024 * 1024
+	maxKDFTime   = 16
+	saltSize     = 16
+)
+
+// encryptedSecret is an encrypted secret split into its parts.
+type encryptedSecret struct {
+	version int
+	keyID   string
+	params  kdfParams
+	salt    []byte
+	header  string
+	data    []byte
+}
+
+// GenerateKey returns a random 32-character encryption key. It is usable both
+// as a passphrase and as a raw key for the original format.
+func GenerateKey() (string, error) {
+
+	key := make([]byte, 24)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(key), nil
+}
+
+// SecretFormat reports the format version (1 for the original format, 2 for
+// passphrase secrets) of an encrypted secret and the key ID recorded in it,
+// which is empty for secrets that do not name their key.
+func SecretFormat(encoded string) (int, string, error) {
+
+	secret, err := parseSecret("secret", encoded)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return secret.version, secret.keyID, nil
+}
+
+// encryptSecret encrypts plaintext into a version 2 secret using a key
+// derived from passphrase. A non-empty keyID is recorded in the header.
+func encryptSecret(plaintext []byte, keyID, passphrase string, params kdfParams) (string, error) {
+
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase is required")
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	gcm, err := newGCM(deriveKey(passphrase, salt, params))
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	paramField := fmt.Sprintf("m=%d,t=%d,p=%d", params.memory, params.time, params.threads)
+	if keyID != "" {
+		paramField += ",kid=" + keyID
+	}
+
+	header := secretPrefixV2 + "argon2id$" + paramField + "$" + base64.RawStdEncoding.EncodeToString(salt)
+	ciphertext := gcm.Seal(nonce, nonce, plaintext, []byte(header))
+
+	return header + "$" + base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// parseSecret splits an encrypted secret in either format, with or without
+// EncryptedPrefix, into its parts. name identifies the secret in error messages.
+func parseSecret(name, encoded string) (*encryptedSecret, error) {
+
+	encoded = strings.TrimPrefix(encoded, EncryptedPrefix)
+	if !strings.HasPrefix(encoded, secretPrefixV2) {
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+		}
+		return &encryptedSecret{version: 1, data: data}, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(encoded, secretPrefixV2), "$")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid %s: expected 4 fields after %s, got %d", name, secretPrefixV2, len(parts))
+	}
+
+	if parts[0] != "argon2id" {
+		return nil, fmt.Errorf("invalid %s: unsupported KDF %q", name, parts[0])
+	}
+
+	params, keyID, err := parseKDFParams(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: failed to decode salt: %w", name, err)
+	}
+
+	if len(salt) < saltSize {
+		return nil, fmt.Errorf("invalid %s: salt must be at least %d bytes", name, saltSize)
+	}
+
+	data, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: failed to decode ciphertext: %w", name, err)
+	}
+
+	return &encryptedSecret{
+		version: 2,
+		keyID:   keyID,
+		params:  params,
+		salt:    salt,
+		header:  encoded[:strings.LastIndex(encoded, "$")],
+		data:    data,
+	}, nil
+}
+
+// open decrypts the secret with key.
+func (s *encryptedSecret) open(key string) ([]byte, error) {
+
+	var aesKey, additionalData []byte
+	if s.version == 2 {
+		aesKey = deriveKey(key, s.salt, s.params)
+		additionalData = []byte(s.header)
+	} else {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key must be 32 bytes for the original format, got %d", len(key))
+		}
+		aesKey = []byte(key)
+	}
+
+	gcm, err := newGCM(aesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(s.data) < nonceSize {
+		return nil, fmt.Errorf("invalid ciphertext: too short")
+	}
+
+	nonce, ciphertext := s.data[:nonceSize], s.data[nonceSize:]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("wrong key or corrupted secret: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// parseKDFParams parses the m=,t=,p= and optional kid= parameter field of a
+// version 2 secret.
+func parseKDFParams(field string) (kdfParams, string, error) {
+
+	var params kdfParams
+	var keyID string
+	for _, kv := range strings.Split(field, ",") {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return params, "", fmt.Errorf("malformed KDF parameter %q", kv)
+		}
+
+		if key == "kid" {
+			if err := validateKeyID(value); err != nil {
+				return params, "", err
+			}
+			keyID = value
+			continue
+		}
+
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return params, "", fmt.Errorf("KDF parameter %s: %w", key, err)
+		}
+
+		switch key {
+		case "m":
+			params.memory = uint32(n)
+		case "t":
+			params.time = uint32(n)
+		case "p":
+			if n > 255 {
+				return params, "", fmt.Errorf("KDF parameter p must be at most 255")
+			}
+			params.threads = uint8(n)
+		default:
+			return params, "", fmt.Errorf("unknown KDF parameter %q", key)
+		}
+	}
+
+	if params.memory == 0 || params.memory > maxKDFMemory {
+		return params, "", fmt.Errorf("KDF memory must be between 1 and %d KiB", maxKDFMemory)
+	}
+
+	if params.time == 0 || params.time > maxKDFTime {
+		return params, "", fmt.Errorf("KDF iterations must be between 1 and %d", maxKDFTime)
+	}
+
+	if params.threads == 0 {
+		return params, "", fmt.Errorf("KDF threads must be at least 1")
+	}
+
+	return params, keyID, nil
+}
+
+// deriveKey derives a 32-byte AES key from passphrase with Argon2id.
+func deriveKey(passphrase string, salt []byte, params kdfParams) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, params.time, params.memory, params.threads, 32)
+}
+
+// newGCM creates an AES-GCM cipher for key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return gcm, nil
+}
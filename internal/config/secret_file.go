@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// getSecret retrieves a secret from the environment variable key or from the
+// file named by key+"_FILE" (e.g., a Docker or Kubernetes secret mounted
+// under /run/secrets), or returns a default. Setting both is an error;
+// empty variables count as unset.
+func getSecret(key, defaultValue string) (string, error) {
+
+	path, fromFile := lookupEnv(key + "_FILE")
+	if !fromFile {
+		return getEnv(key, defaultValue), nil
+	}
+
+	if _, exists := lookupEnv(key); exists {
+		return defaultValue, fmt.Errorf("only one of %s and %s_FILE may be set", key, key)
+	}
+
+	return readSecretFile(key+"_FILE", path)
+}
+
+// readSecretFile reads a secret from path, trimming trailing newlines. Like
+// ssh with private keys, files that the group or others can access are
+// refused: anyone able to read them has the secret, and anyone able to
+// modify them could redirect DNS updates.
+func readSecretFile(name, path string) (string, error) {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	if info.IsDir() {
+		return "", fmt.Errorf("failed to read %s: %s is a directory", name, path)
+	}
+
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		return "", fmt.Errorf("refusing to read %s: %s has mode %s and is accessible by group or others; use mode 0400 or 0600", name, path, perm)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSecretFile writes a secret to a file with the given mode.
+func writeSecretFile(t *testing.T, contents string, mode os.FileMode) string {
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(path, []byte(contents), mode))
+	require.NoError(t, os.Chmod(path, mode))
+	return path
+}
+
+func TestNewConfig_SecretFiles(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	os.Setenv("OUTPUT_FILE", filepath.Join(t.TempDir(), "ip_log.txt"))
+	os.Setenv("ZONOMI_HOSTS", "example.com")
+	os.Setenv("ZONOMI_API_KEY_FILE", writeSecretFile(t, "file-api-key\n", 0400))
+	os.Setenv("ZONOMI_ENCRYPTION_KEY_FILE", writeSecretFile(t, "file-encryption-key\r\n", 0600))
+	os.Setenv("ADMIN_TOKEN_FILE", writeSecretFile(t, "file-admin-token\n", 0400))
+
+	// Load config
+	cfg, err := New()
+	require.NoError(t, err)
+
+	// Trailing newlines are trimmed
+	assert.Equal(t, "file-api-key", cfg.ZonomiAPIKey.Reveal())
+	assert.Equal(t, "file-encryption-key", cfg.ZonomiEncryptionKey)
+	assert.Equal(t, "file-admin-token", cfg.AdminToken.Reveal())
+}
+
+func TestNewConfig_SecretFileWithEmptyVariable(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	// The Dockerfile declares ZONOMI_API_KEY with an empty value
+	os.Setenv("OUTPUT_FILE", filepath.Join(t.TempDir(), "ip_log.txt"))
+	os.Setenv("ZONOMI_HOSTS", "example.com")
+	os.Setenv("ZONOMI_API_KEY", "")
+	os.Setenv("ZONOMI_API_KEY_FILE", writeSecretFile(t, "file-api-key\n", 0400))
+
+	// Load config
+	cfg, err := New()
+	require.NoError(t, err)
+	assert.Equal(t, "file-api-key", cfg.ZonomiAPIKey.Reveal())
+}
+
+func TestNewConfig_EncryptedAPIKeyFromSecretFiles(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	encryptKey := "0123456789abcdef0123456789abcdef"
+	encryptedAPIKey, err := encrypt([]byte("test-api-key"), []byte(encryptKey))
+	require.NoError(t, err)
+
+	os.Setenv("OUTPUT_FILE", filepath.Join(t.TempDir(), "ip_log.txt"))
+	os.Setenv("ZONOMI_HOSTS", "example.com")
+	os.Setenv("ZONOMI_API_ENCRYPTED", "true")
+	os.Setenv("ZONOMI_API_KEY_FILE", writeSecretFile(t, encryptedAPIKey+"\n", 0600))
+	os.Setenv("ZONOMI_ENCRYPTION_KEY_FILE", writeSecretFile(t, encryptKey+"\n", 0600))
+
+	// Load config
+	cfg, err := New()
+	require.NoError(t, err)
+	assert.Equal(t, "test-api-key", cfg.ZonomiAPIKey.Reveal())
+}
+
+func TestNewConfig_SecretFileErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(t *testing.T)
+		expectedErr string
+	}{
+		{
+			name: "Both set",
+			setup: func(t *testing.T) {
+				os.Setenv("ZONOMI_API_KEY", "env-api-key")
+				os.Setenv("ZONOMI_API_KEY_FILE", writeSecretFile(t, "file-api-key", 0400))
+			},
+			expectedErr: "only one of ZONOMI_API_KEY and ZONOMI_API_KEY_FILE may be set",
+		},
+		{
+			name: "Missing file",
+			setup: func(t *testing.T) {
+				os.Setenv("ZONOMI_API_KEY_FILE", filepath.Join(t.TempDir(), "missing"))
+			},
+			expectedErr: "failed to read ZONOMI_API_KEY_FILE",
+		},
+		{
+			name: "World writable",
+			setup: func(t *testing.T) {
+				os.Setenv("ZONOMI_API_KEY_FILE", writeSecretFile(t, "file-api-key", 0666))
+			},
+			expectedErr: "refusing to read ZONOMI_API_KEY_FILE",
+		},
+		{
+			name: "Group writable",
+			setup: func(t *testing.T) {
+				os.Setenv("ZONOMI_ENCRYPTION_KEY_FILE", writeSecretFile(t, "key", 0620))
+			},
+			expectedErr: "accessible by group or others",
+		},
+		{
+			name: "World readable",
+			setup: func(t *testing.T) {
+				os.Setenv("ZONOMI_API_KEY_FILE", writeSecretFile(t, "file-api-key", 0444))
+			},
+			expectedErr: "refusing to read ZONOMI_API_KEY_FILE",
+		},
+		{
+			name: "Group readable",
+			setup: func(t *testing.T) {
+				os.Setenv("ADMIN_TOKEN_FILE", writeSecretFile(t, "admin-token", 0440))
+			},
+			expectedErr: "refusing to read ADMIN_TOKEN_FILE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Clear environment variables
+			os.Clearenv()
+
+			os.Setenv("OUTPUT_FILE", filepath.Join(t.TempDir(), "ip_log.txt"))
+			os.Setenv("ZONOMI_HOSTS", "example.com")
+			tt.setup(t)
+
+			// Load config
+			_, err := New()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func TestLoad_SecretFileFromConfigFile(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	path := writeConfigFile(t, `
+output_file: `+filepath.Join(t.TempDir(), "ip_log.txt")+`
+zonomi:
+  hosts: [example.com]
+  api_key_file: `+writeSecretFile(t, "file-api-key\n", 0400)+`
+`)
+
+	// Load config
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "file-api-key", cfg.ZonomiAPIKey.Reveal())
+}
+
+func TestLoad_SecretAndSecretFileInConfigFile(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	path := writeConfigFile(t, `
+zonomi:
+  api_key: inline-api-key
+  api_key_file: `+writeSecretFile(t, "file-api-key", 0400)+`
+`)
+
+	// Load config
+	_, err := Load(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only one of zonomi.api_key and zonomi.api_key_file may be set")
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKDFParams keeps Argon2id cheap in tests.
+var testKDFParams = kdfParams{memory: 1024, time: 1, threads: 1}
+
+// openSecret parses and decrypts an encrypted secret with key.
+func openSecret(encoded, key string) ([]byte, error) {
+	secret, err := parseSecret("secret", encoded)
+	if err != nil {
+		return nil, err
+	}
+	return secret.open(key)
+}
+
+func TestEncryptSecret_RoundTrip(t *testing.T) {
+	encoded, err := encryptSecret([]byte("test-api-key"), "", "correct horse battery staple", testKDFParams)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$zc2$argon2id$m=1024,t=1,p=1$"))
+
+	plaintext, err := openSecret(encoded, "correct horse battery staple")
+	require.NoError(t, err)
+	assert.Equal(t, "test-api-key", string(plaintext))
+
+	// Each encryption uses a fresh salt and nonce
+	again, err := encryptSecret([]byte("test-api-key"), "", "correct horse battery staple", testKDFParams)
+	require.NoError(t, err)
+	assert.NotEqual(t, encoded, again)
+}
+
+func TestOpenSecret_Errors(t *testing.T) {
+	encoded, err := encryptSecret([]byte("test-api-key"), "", "passphrase", testKDFParams)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		encoded     string
+		passphrase  string
+		expectedErr string
+	}{
+		{
+			name:        "Wrong passphrase",
+			encoded:     encoded,
+			passphrase:  "not the passphrase",
+			expectedErr: "wrong key or corrupted secret",
+		},
+		{
+			name:        "Tampered parameters",
+			encoded:     strings.Replace(encoded, "t=1", "t=2", 1),
+			passphrase:  "passphrase",
+			expectedErr: "wrong key or corrupted secret",
+		},
+		{
+			name:        "Excessive memory",
+			encoded:     strings.Replace(encoded, "m=1024", "m=99999999", 1),
+			passphrase:  "passphrase",
+			expectedErr: "KDF memory must be between",
+		},
+		{
+			name:        "Unknown KDF",
+			encoded:     strings.Replace(encoded, "argon2id", "pbkdf2", 1),
+			passphrase:  "passphrase",
+			expectedErr: `unsupported KDF "pbkdf2"`,
+		},
+		{
+			name:        "Missing fields",
+			encoded:     "$zc2$argon2id$m=1024,t=1,p=1",
+			passphrase:  "passphrase",
+			expectedErr: "expected 4 fields",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := openSecret(tt.encoded, tt.passphrase)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func TestNewConfig_PassphraseEncryptedAPIKey(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	// A passphrase of any length can be used with the version 2 format
+	passphrase := "a short passphrase"
+	encryptedAPIKey, err := encryptSecret([]byte("test-api-key"), "", passphrase, testKDFParams)
+	require.NoError(t, err)
+
+	os.Setenv("OUTPUT_FILE", filepath.Join(t.TempDir(), "ip_log.txt"))
+	os.Setenv("ZONOMI_HOSTS", "example.com")
+	os.Setenv("ZONOMI_API_KEY", encryptedAPIKey)
+	os.Setenv("ZONOMI_API_ENCRYPTED", "true")
+	os.Setenv("ZONOMI_ENCRYPTION_KEY", passphrase)
+
+	// Load config
+	cfg, err := New()
+	require.NoError(t, err)
+	assert.Equal(t, "test-api-key", cfg.ZonomiAPIKey.Reveal())
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// EncryptedPrefix marks a secret config value that is stored encrypted and
+// decrypted with the keyring at load time (e.g., "enc:$zc2$argon2id$...").
+const EncryptedPrefix = "enc:"
+
+// redacted replaces secret values wherever they are formatted or logged.
+const redacted = "[REDACTED]"
+
+// Secret is a config value that must never be logged. It formats, logs and
+// marshals as "[REDACTED]"; use Reveal where the plaintext is needed.
+type Secret string
+
+// Reveal returns the plaintext value.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// String returns "[REDACTED]", or "" for an empty secret.
+func (s Secret) String() string {
+
+	if s == "" {
+		return ""
+	}
+
+	return redacted
+}
+
+// GoString keeps the plaintext out of %#v output.
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// LogValue keeps the plaintext out of slog output.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// MarshalText keeps the plaintext out of JSON and other text encodings.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// secretField is a Secret config field that may be stored encrypted.
+type secretField struct {
+	name  string
+	value *Secret
+
+	// encrypted marks the value as encrypted even without EncryptedPrefix
+	encrypted bool
+}
+
+// secretFields lists every Secret field of c. New secret fields only need to
+// be added here to support encryption.
+func (c *Config) secretFields() []secretField {
+	return []secretField{
+		{name: "ZONOMI_API_KEY", value: &c.ZonomiAPIKey, encrypted: c.ZonomiAPIEncrypted},
+		{name: "ADMIN_TOKEN", value: &c.AdminToken},
+	}
+}
+
+// isEncrypted reports whether the field holds an encrypted value.
+func (f secretField) isEncrypted() bool {
+	return *f.value != "" && (f.encrypted || strings.HasPrefix(string(*f.value), EncryptedPrefix))
+}
+
+// decryptSecrets decrypts every encrypted secret field of c in place.
+func (c *Config) decryptSecrets() []error {
+
+	keyring := c.Keyring()
+	if len(keyring.Keys) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, field := range c.secretFields() {
+		if !field.isEncrypted() {
+			continue
+		}
+
+		plaintext, _, err := keyring.Decrypt(field.name, string(*field.value))
+		if err != nil {
+			errs = append(errs, err)
+		}
+		*field.value = Secret(plaintext)
+	}
+
+	return errs
+}
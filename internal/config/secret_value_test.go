@@ -0,0 +1,68 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecret_NeverFormatsPlaintext(t *testing.T) {
+	secret := Secret("super-secret-value")
+
+	assert.Equal(t, "super-secret-value", secret.Reveal())
+	assert.Equal(t, "[REDACTED]", fmt.Sprint(secret))
+	for _, verb := range []string{"%s", "%v", "%+v", "%#v", "%q"} {
+		assert.NotContains(t, fmt.Sprintf(verb, secret), "super-secret-value", verb)
+	}
+	assert.NotContains(t, fmt.Sprintf("%+v %#v", validConfig(), validConfig()), "test-api-key")
+
+	data, err := json.Marshal(map[string]Secret{"api_key": secret})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"api_key":"[REDACTED]"}`, string(data))
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("test", "api_key", secret)
+	assert.NotContains(t, buf.String(), "super-secret-value")
+
+	assert.Equal(t, "", Secret("").String())
+}
+
+func TestNewConfig_EncPrefix(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	encrypted, err := encryptSecret([]byte("test-api-key"), "", "passphrase", testKDFParams)
+	require.NoError(t, err)
+
+	// The enc: prefix marks the value as encrypted without ZONOMI_API_ENCRYPTED
+	os.Setenv("OUTPUT_FILE", filepath.Join(t.TempDir(), "ip_log.txt"))
+	os.Setenv("ZONOMI_HOSTS", "example.com")
+	os.Setenv("ZONOMI_API_KEY", EncryptedPrefix+encrypted)
+	os.Setenv("ZONOMI_ENCRYPTION_KEY", "passphrase")
+
+	// Load config
+	cfg, err := New()
+	require.NoError(t, err)
+	assert.Equal(t, "test-api-key", cfg.ZonomiAPIKey.Reveal())
+}
+
+func TestNewConfig_EncPrefixWithoutKey(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	os.Setenv("OUTPUT_FILE", filepath.Join(t.TempDir(), "ip_log.txt"))
+	os.Setenv("ZONOMI_HOSTS", "example.com")
+	os.Setenv("ZONOMI_API_KEY", "enc:$zc2$argon2id$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$ZGF0YQ")
+
+	// Load config
+	_, err := New()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ZONOMI_ENCRYPTION_KEY is required to decrypt ZONOMI_API_KEY")
+}
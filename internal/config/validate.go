@@ -0,0 +1,263 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxRetriesLimit is the largest accepted value for MAX_RETRIES.
+const MaxRetriesLimit = 10
+
+// UnixAddrPrefix marks an HTTP_ADDR that is a Unix socket path.
+const UnixAddrPrefix = "unix:"
+
+// Validate checks every field of the configuration and reports all problems
+// at once, joined into a single error.
+func (c *Config) Validate() error {
+
+	var errs []error
+
+	// URLs must be absolute http(s) URLs
+	if err := validateURL("API_URL", c.APIURL); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateURL("ZONOMI_API_URL", c.ZonomiAPIURL); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.ZonomiAuthMode != AuthModeQuery && c.ZonomiAuthMode != AuthModePost {
+		errs = append(errs, fmt.Errorf("ZONOMI_AUTH_MODE must be %q or %q, got %q", AuthModeQuery, AuthModePost, c.ZonomiAuthMode))
+	}
+
+	if c.OutputFile == "" {
+		errs = append(errs, fmt.Errorf("OUTPUT_FILE is required"))
+	}
+
+	switch c.StateBackend {
+	case StateBackendFile:
+	case StateBackendBolt:
+		if c.StatePath == "" {
+			errs = append(errs, fmt.Errorf("STATE_PATH is required when STATE_BACKEND is %q", StateBackendBolt))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("STATE_BACKEND must be %q or %q, got %q", StateBackendFile, StateBackendBolt, c.StateBackend))
+	}
+
+	if c.IPLogMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("IP_LOG_MAX_AGE must not be negative, got %s", c.IPLogMaxAge))
+	}
+
+	if c.IPLogMaxEntries < 0 {
+		errs = append(errs, fmt.Errorf("IP_LOG_MAX_ENTRIES must not be negative, got %d", c.IPLogMaxEntries))
+	}
+
+	if c.IPLogMaxSize < 0 {
+		errs = append(errs, fmt.Errorf("IP_LOG_MAX_SIZE must not be negative, got %d", c.IPLogMaxSize))
+	}
+
+	if c.IPLogHeartbeat < 0 {
+		errs = append(errs, fmt.Errorf("IP_LOG_HEARTBEAT must not be negative, got %s", c.IPLogHeartbeat))
+	}
+
+	if c.MaxRetries < 0 || c.MaxRetries > MaxRetriesLimit {
+		errs = append(errs, fmt.Errorf("MAX_RETRIES must be between 0 and %d, got %d", MaxRetriesLimit, c.MaxRetries))
+	}
+
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("invalid TIMEZONE %q: %w", c.Timezone, err))
+	}
+
+	if _, _, err := ParseScheduleTime(c.ScheduleTime); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Schedule != "" {
+		if _, err := ParseSchedule(c.Schedule); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.RunTimeout < 0 {
+		errs = append(errs, fmt.Errorf("RUN_TIMEOUT must not be negative, got %s", c.RunTimeout))
+	}
+
+	if c.OverlapPolicy != OverlapSkip && c.OverlapPolicy != OverlapQueue {
+		errs = append(errs, fmt.Errorf("OVERLAP_POLICY must be %q or %q, got %q", OverlapSkip, OverlapQueue, c.OverlapPolicy))
+	}
+
+	if c.ScheduleJitter < 0 {
+		errs = append(errs, fmt.Errorf("SCHEDULE_JITTER must not be negative, got %s", c.ScheduleJitter))
+	}
+
+	if c.AdaptiveSchedule {
+		if c.AdaptiveMinInterval < MinScheduleInterval {
+			errs = append(errs, fmt.Errorf("ADAPTIVE_MIN_INTERVAL must be at least %s, got %s", MinScheduleInterval, c.AdaptiveMinInterval))
+		}
+
+		if c.AdaptiveMaxInterval < c.AdaptiveMinInterval {
+			errs = append(errs, fmt.Errorf("ADAPTIVE_MAX_INTERVAL must not be less than ADAPTIVE_MIN_INTERVAL, got %s < %s", c.AdaptiveMaxInterval, c.AdaptiveMinInterval))
+		}
+	}
+
+	if c.NetworkWatch && c.NetworkWatchDebounce <= 0 {
+		errs = append(errs, fmt.Errorf("NETWORK_WATCH_DEBOUNCE must be positive, got %s", c.NetworkWatchDebounce))
+	}
+
+	for i, value := range c.MaintenanceWindows {
+		if _, err := ParseMaintenanceWindow(value); err != nil {
+			errs = append(errs, fmt.Errorf("MAINTENANCE_WINDOWS entry %d: %w", i+1, err))
+		}
+	}
+
+	if c.HealthMaxFailures < 0 {
+		errs = append(errs, fmt.Errorf("HEALTH_MAX_FAILURES must not be negative, got %d", c.HealthMaxFailures))
+	}
+
+	if c.HealthMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("HEALTH_MAX_AGE must not be negative, got %s", c.HealthMaxAge))
+	}
+
+	if c.HTTPAddr == "" || c.HTTPAddr == UnixAddrPrefix {
+		errs = append(errs, fmt.Errorf("HTTP_ADDR must be a host:port or unix:/path address"))
+	}
+
+	if (c.HTTPTLSCertFile == "") != (c.HTTPTLSKeyFile == "") {
+		errs = append(errs, fmt.Errorf("HTTP_TLS_CERT_FILE and HTTP_TLS_KEY_FILE must be set together"))
+	}
+
+	if c.HTTPReadTimeout < 0 {
+		errs = append(errs, fmt.Errorf("HTTP_READ_TIMEOUT must not be negative, got %s", c.HTTPReadTimeout))
+	}
+
+	if c.HTTPWriteTimeout < 0 {
+		errs = append(errs, fmt.Errorf("HTTP_WRITE_TIMEOUT must not be negative, got %s", c.HTTPWriteTimeout))
+	}
+
+	if c.HTTPShutdownTimeout < 0 {
+		errs = append(errs, fmt.Errorf("HTTP_SHUTDOWN_TIMEOUT must not be negative, got %s", c.HTTPShutdownTimeout))
+	}
+
+	if c.ConfigWatchInterval < 0 {
+		errs = append(errs, fmt.Errorf("CONFIG_WATCH_INTERVAL must not be negative, got %s", c.ConfigWatchInterval))
+	}
+
+	// Every host must be a valid hostname
+	if len(c.ZonomiHosts) == 0 {
+		errs = append(errs, fmt.Errorf("ZONOMI_HOSTS is required"))
+	}
+
+	for i, host := range c.ZonomiHosts {
+		if err := validateHostname(host); err != nil {
+			errs = append(errs, fmt.Errorf("ZONOMI_HOSTS entry %d: %w", i+1, err))
+		}
+	}
+
+	if c.ZonomiAPIKey == "" {
+		errs = append(errs, fmt.Errorf("ZONOMI_API_KEY is required"))
+	}
+
+	if c.ZonomiAPIEncrypted && c.ZonomiEncryptionKey == "" && len(c.ZonomiEncryptionKeys) == 0 {
+		errs = append(errs, fmt.Errorf("ZONOMI_ENCRYPTION_KEY is required when ZONOMI_API_ENCRYPTED is true"))
+	}
+
+	for _, field := range c.secretFields() {
+		if field.isEncrypted() && !field.encrypted && c.ZonomiEncryptionKey == "" && len(c.ZonomiEncryptionKeys) == 0 {
+			errs = append(errs, fmt.Errorf("ZONOMI_ENCRYPTION_KEY is required to decrypt %s", field.name))
+		}
+	}
+
+	errs = append(errs, validateKeys(c)...)
+
+	return errors.Join(errs...)
+}
+
+// ParseScheduleTime parses a SCHEDULE_TIME value (e.g., "23:59") into hour and minute.
+func ParseScheduleTime(scheduleTime string) (int, int, error) {
+	return parseTimeOfDay("SCHEDULE_TIME", scheduleTime)
+}
+
+// parseTimeOfDay parses an HH:MM value of the named setting into hour and
+// minute.
+func parseTimeOfDay(name, value string) (int, int, error) {
+
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid %s format: %s, expected HH:MM", name, value)
+	}
+
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid %s hour: %w", name, err)
+	}
+
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid %s minute: %w", name, err)
+	}
+
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, 0, fmt.Errorf("invalid %s: hour must be 0-23, minute must be 0-59, got %d:%d", name, hour, minute)
+	}
+
+	return hour, minute, nil
+}
+
+// validateURL checks that value is an absolute http or https URL.
+func validateURL(name, value string) error {
+
+	if value == "" {
+		return fmt.Errorf("%s is required", name)
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid %s %q: scheme must be http or https", name, value)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("invalid %s %q: missing host", name, value)
+	}
+
+	return nil
+}
+
+// validateHostname checks that host is a valid RFC 1123 hostname. A leading
+// "*." label is allowed for wildcard records.
+func validateHostname(host string) error {
+
+	if host == "" {
+		return fmt.Errorf("hostname is empty")
+	}
+
+	name := strings.TrimSuffix(strings.TrimPrefix(host, "*."), ".")
+	if len(name) > 253 {
+		return fmt.Errorf("hostname %q is longer than 253 characters", host)
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return fmt.Errorf("hostname %q has a label that is empty or longer than 63 characters", host)
+		}
+
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("hostname %q has a label that starts or ends with a hyphen", host)
+		}
+
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' {
+				return fmt.Errorf("hostname %q contains invalid character %q", host, r)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validConfig returns a Config that passes validation.
+func validConfig() Config {
+	return Config{
+		APIURL:         "https://api.ipify.org?format=json",
+		OutputFile:     "/app/data/ip_log.log",
+		StateBackend:   StateBackendFile,
+		StatePath:      "/app/data/state.db",
+		MaxRetries:     3,
+		Timezone:       "UTC",
+		ScheduleTime:   "23:59",
+		ZonomiHosts:    []string{"example.com", "*.example.com", "host-1.example.com."},
+		ZonomiAPIKey:   "test-api-key",
+		ZonomiAPIURL:   "https://zonomi.com/app/dns/dyndns.jsp",
+		ZonomiAuthMode: AuthModeQuery,
+		OverlapPolicy:  OverlapSkip,
+		HTTPAddr:       ":8000",
+	}
+}
+
+func TestValidate_Valid(t *testing.T) {
+	cfg := validConfig()
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidate_Fields(t *testing.T) {
+	tests := []struct {
+		name        string
+		modify      func(*Config)
+		expectedErr string
+	}{
+		{
+			name:        "API URL scheme",
+			modify:      func(c *Config) { c.APIURL = "ftp://api.ipify.org" },
+			expectedErr: "invalid API_URL \"ftp://api.ipify.org\": scheme must be http or https",
+		},
+		{
+			name:        "Zonomi URL missing host",
+			modify:      func(c *Config) { c.ZonomiAPIURL = "https://" },
+			expectedErr: "invalid ZONOMI_API_URL \"https://\": missing host",
+		},
+		{
+			name:        "State backend",
+			modify:      func(c *Config) { c.StateBackend = "sqlite" },
+			expectedErr: `STATE_BACKEND must be "file" or "bolt", got "sqlite"`,
+		},
+		{
+			name: "State path",
+			modify: func(c *Config) {
+				c.StateBackend = StateBackendBolt
+				c.StatePath = ""
+			},
+			expectedErr: `STATE_PATH is required when STATE_BACKEND is "bolt"`,
+		},
+		{
+			name:        "IP log max entries",
+			modify:      func(c *Config) { c.IPLogMaxEntries = -1 },
+			expectedErr: "IP_LOG_MAX_ENTRIES must not be negative, got -1",
+		},
+		{
+			name:        "IP log max age",
+			modify:      func(c *Config) { c.IPLogMaxAge = -time.Hour },
+			expectedErr: "IP_LOG_MAX_AGE must not be negative, got -1h0m0s",
+		},
+		{
+			name:        "Auth mode",
+			modify:      func(c *Config) { c.ZonomiAuthMode = "header" },
+			expectedErr: `ZONOMI_AUTH_MODE must be "query" or "post", got "header"`,
+		},
+		{
+			name:        "Output file",
+			modify:      func(c *Config) { c.OutputFile = "" },
+			expectedErr: "OUTPUT_FILE is required",
+		},
+		{
+			name:        "Retries too high",
+			modify:      func(c *Config) { c.MaxRetries = 50 },
+			expectedErr: "MAX_RETRIES must be between 0 and 10, got 50",
+		},
+		{
+			name:        "Retries negative",
+			modify:      func(c *Config) { c.MaxRetries = -1 },
+			expectedErr: "MAX_RETRIES must be between 0 and 10, got -1",
+		},
+		{
+			name:        "Timezone",
+			modify:      func(c *Config) { c.Timezone = "Mars/Olympus" },
+			expectedErr: "invalid TIMEZONE \"Mars/Olympus\"",
+		},
+		{
+			name:        "Schedule time",
+			modify:      func(c *Config) { c.ScheduleTime = "24:00" },
+			expectedErr: "invalid SCHEDULE_TIME: hour must be 0-23",
+		},
+		{
+			name:        "Schedule",
+			modify:      func(c *Config) { c.Schedule = "every day" },
+			expectedErr: "invalid SCHEDULE \"every day\"",
+		},
+		{
+			name:        "Run timeout",
+			modify:      func(c *Config) { c.RunTimeout = -time.Second },
+			expectedErr: "RUN_TIMEOUT must not be negative, got -1s",
+		},
+		{
+			name:        "Overlap policy",
+			modify:      func(c *Config) { c.OverlapPolicy = "wait" },
+			expectedErr: `OVERLAP_POLICY must be "skip" or "queue", got "wait"`,
+		},
+		{
+			name:        "Schedule jitter",
+			modify:      func(c *Config) { c.ScheduleJitter = -time.Minute },
+			expectedErr: "SCHEDULE_JITTER must not be negative, got -1m0s",
+		},
+		{
+			name: "Adaptive min interval",
+			modify: func(c *Config) {
+				c.AdaptiveSchedule = true
+				c.AdaptiveMinInterval = 10 * time.Second
+				c.AdaptiveMaxInterval = time.Hour
+			},
+			expectedErr: "ADAPTIVE_MIN_INTERVAL must be at least 1m0s, got 10s",
+		},
+		{
+			name: "Network watch debounce",
+			modify: func(c *Config) {
+				c.NetworkWatch = true
+				c.NetworkWatchDebounce = 0
+			},
+			expectedErr: "NETWORK_WATCH_DEBOUNCE must be positive, got 0s",
+		},
+		{
+			name:        "Maintenance window",
+			modify:      func(c *Config) { c.MaintenanceWindows = []string{"0 2 * * 0 2h", "0 2 * * 0"} },
+			expectedErr: "MAINTENANCE_WINDOWS entry 2: invalid maintenance window \"0 2 * * 0\"",
+		},
+		{
+			name:        "Health max failures",
+			modify:      func(c *Config) { c.HealthMaxFailures = -1 },
+			expectedErr: "HEALTH_MAX_FAILURES must not be negative, got -1",
+		},
+		{
+			name:        "HTTP TLS",
+			modify:      func(c *Config) { c.HTTPTLSCertFile = "/etc/zonocaller/cert.pem" },
+			expectedErr: "HTTP_TLS_CERT_FILE and HTTP_TLS_KEY_FILE must be set together",
+		},
+		{
+			name:        "HTTP addr",
+			modify:      func(c *Config) { c.HTTPAddr = "unix:" },
+			expectedErr: "HTTP_ADDR must be a host:port or unix:/path address",
+		},
+		{
+			name: "Adaptive max interval",
+			modify: func(c *Config) {
+				c.AdaptiveSchedule = true
+				c.AdaptiveMinInterval = time.Hour
+				c.AdaptiveMaxInterval = time.Minute
+			},
+			expectedErr: "ADAPTIVE_MAX_INTERVAL must not be less than ADAPTIVE_MIN_INTERVAL, got 1m0s < 1h0m0s",
+		},
+		{
+			name:        "No hosts",
+			modify:      func(c *Config) { c.ZonomiHosts = nil },
+			expectedErr: "ZONOMI_HOSTS is required",
+		},
+		{
+			name:        "Empty host",
+			modify:      func(c *Config) { c.ZonomiHosts = []string{"a.com", "", "b.com"} },
+			expectedErr: "ZONOMI_HOSTS entry 2: hostname is empty",
+		},
+		{
+			name:        "Host with underscore",
+			modify:      func(c *Config) { c.ZonomiHosts = []string{"bad_host.com"} },
+			expectedErr: "contains invalid character '_'",
+		},
+		{
+			name:        "Host label hyphen",
+			modify:      func(c *Config) { c.ZonomiHosts = []string{"-bad.com"} },
+			expectedErr: "starts or ends with a hyphen",
+		},
+		{
+			name:        "Host label too long",
+			modify:      func(c *Config) { c.ZonomiHosts = []string{strings.Repeat("a", 64) + ".com"} },
+			expectedErr: "longer than 63 characters",
+		},
+		{
+			name:        "API key",
+			modify:      func(c *Config) { c.ZonomiAPIKey = "" },
+			expectedErr: "ZONOMI_API_KEY is required",
+		},
+		{
+			name:        "Encryption key",
+			modify:      func(c *Config) { c.ZonomiAPIEncrypted = true },
+			expectedErr: "ZONOMI_ENCRYPTION_KEY is required when ZONOMI_API_ENCRYPTED is true",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func TestNewConfig_ReportsAllErrors(t *testing.T) {
+	// Clear environment variables
+	os.Clearenv()
+
+	// Set several invalid values at once
+	os.Setenv("OUTPUT_FILE", filepath.Join(t.TempDir(), "ip_log.txt"))
+	os.Setenv("MAX_RETRIES", "three")
+	os.Setenv("RUN_ONCE", "yes")
+	os.Setenv("TIMEZONE", "Nowhere/Special")
+	os.Setenv("SCHEDULE_TIME", "noon")
+	os.Setenv("API_URL", "api.ipify.org")
+	os.Setenv("ZONOMI_HOSTS", "a.com,,b.com")
+	os.Setenv("IP_LOG_MAX_AGE", "30d")
+
+	// Load config
+	_, err := New()
+	require.Error(t, err)
+
+	for _, expected := range []string{
+		`MAX_RETRIES must be an integer, got "three"`,
+		`RUN_ONCE must be true or false, got "yes"`,
+		`invalid TIMEZONE "Nowhere/Special"`,
+		"invalid SCHEDULE_TIME format: noon",
+		`invalid API_URL "api.ipify.org": scheme must be http or https`,
+		"ZONOMI_HOSTS entry 2: hostname is empty",
+		"ZONOMI_API_KEY is required",
+		`IP_LOG_MAX_AGE must be a duration such as 30s or 5m, got "30d"`,
+	} {
+		assert.Contains(t, err.Error(), expected)
+	}
+}
+
+func TestParseScheduleTime(t *testing.T) {
+	hour, minute, err := ParseScheduleTime("07:05")
+	require.NoError(t, err)
+	assert.Equal(t, 7, hour)
+	assert.Equal(t, 5, minute)
+}
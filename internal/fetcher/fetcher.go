@@ -1,19 +1,23 @@
 package fetcher
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/Drakx/ZonoCaller/internal/logging"
+	"github.com/Drakx/ZonoCaller/internal/state"
 	"github.com/cenkalti/backoff/v4"
+	"github.com/google/uuid"
 )
 
 // IPResponse represents the ipify API response
@@ -22,10 +26,7 @@ type IPResponse struct {
 }
 
 // IPLogEntry represents a single entry in the ip log file
-type IPLogEntry struct {
-	IP        string `json:"ip"`
-	Timestamp string `json:timestamp`
-}
+type IPLogEntry = state.Entry
 
 // FetcherInterface defines the interface for Fetcher
 type FetcherInterface interface {
@@ -34,63 +35,192 @@ type FetcherInterface interface {
 
 // Fetcher handles IP fetching, comparison, and DNS updates
 type Fetcher struct {
+	mu           sync.RWMutex
 	logger       *slog.Logger
 	client       *http.Client
 	config       config.Config
+	dnsRequests  DNSRequestBuilder
+	store        state.Store
 	retryBackoff backoff.BackOff
+	metrics      Metrics
+
+	resultMu   sync.Mutex
+	lastResult state.Entry
+}
+
+// APIs the fetcher calls, as reported to Metrics.
+const (
+	APIIPSource = "ip_source"
+	APIZonomi   = "zonomi"
+)
+
+// Metrics receives measurements of the fetcher's HTTP requests.
+type Metrics interface {
+	// ObserveRequest records the latency of a request attempt to api.
+	ObserveRequest(api string, duration time.Duration)
+	// Retry records a retry of a failed request to api.
+	Retry(api string)
+}
+
+// nopMetrics discards measurements.
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveRequest(string, time.Duration) {}
+func (nopMetrics) Retry(string)                         {}
+
+// forceKey marks a context whose run updates DNS even if the IP is unchanged.
+type forceKey struct{}
+
+// ForceUpdate returns a context that makes FetchIP update DNS even if the IP
+// is unchanged.
+func ForceUpdate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceKey{}, true)
+}
+
+// Forced reports whether ctx was returned by ForceUpdate.
+func Forced(ctx context.Context) bool {
+	force, _ := ctx.Value(forceKey{}).(bool)
+	return force
+}
+
+// Option configures a Fetcher
+type Option func(*Fetcher)
+
+// WithStore keeps state in store instead of the JSON-lines OUTPUT_FILE.
+func WithStore(store state.Store) Option {
+	return func(f *Fetcher) {
+		f.store = store
+	}
+}
+
+// WithMetrics reports request latencies and retries to metrics.
+func WithMetrics(metrics Metrics) Option {
+	return func(f *Fetcher) {
+		f.metrics = metrics
+	}
 }
 
 // New creates a new Fetcher instance
-func New(cfg config.Config) *Fetcher {
+func New(cfg config.Config, opts ...Option) *Fetcher {
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level:     slog.LevelInfo,
-		AddSource: true,
-	}))
+	logger := logging.New(os.Stdout)
 
 	client := &http.Client{Timeout: 10 * time.Second}
 
-	return &Fetcher{
-		logger: logger,
-		client: client,
-		config: cfg,
+	f := &Fetcher{
+		logger:      logger,
+		client:      client,
+		config:      cfg,
+		dnsRequests: newZonomiRequestBuilder(cfg),
+		store:       state.NewFileStore(cfg.OutputFile),
+		metrics:     nopMetrics{},
 		retryBackoff: backoff.NewExponentialBackOff(
 			backoff.WithInitialInterval(1*time.Second),
 			backoff.WithMaxInterval(10*time.Second),
 			backoff.WithMaxElapsedTime(30*time.Second),
 		),
 	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// Reload swaps in a new configuration. A run in progress finishes with the
+// configuration it started with. The state store is kept.
+func (f *Fetcher) Reload(cfg config.Config) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.config = cfg
+	f.dnsRequests = newZonomiRequestBuilder(cfg)
+}
+
+// FetchIP retrieves the public IP, checks for changes, and updates DNS if
+// needed. Every run is recorded in the history with its outcome. HTTP
+// requests and retries stop when ctx is cancelled or its deadline passes.
+func (f *Fetcher) FetchIP(ctx context.Context) error {
+
+	// Hold the config steady for the whole run
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	start := time.Now()
+	run := state.Entry{
+		Version:   state.EntryVersion,
+		RunID:     uuid.NewString(),
+		Timestamp: start.Format(time.RFC3339),
+		Sources:   []string{logging.Redact(f.config.APIURL)},
+	}
+
+	f.logger.Info("Fetching public IP", "url", f.config.APIURL, "run_id", run.RunID)
+
+	err := f.run(ctx, &run)
+	if err != nil {
+		run.Error = logging.Redact(err.Error())
+	}
+	run.DurationMS = time.Since(start).Milliseconds()
+
+	// The scheduler uses the last run time to catch up after downtime
+	if err := f.store.SetLastRun(start); err != nil {
+		f.logger.Warn("Failed to record last run time", "error", err)
+	}
+
+	f.resultMu.Lock()
+	f.lastResult = run
+	f.resultMu.Unlock()
+
+	if recordErr := f.record(run); recordErr != nil && err == nil {
+		return recordErr
+	}
+
+	return err
 }
 
-// FetchIP retrieves the public IP, checks for changes, and updates DNS if needed.
-func (f *Fetcher) FetchIP(_ context.Context) error {
+// LastResult returns the record of the most recent run, whether or not it
+// was written to the history.
+func (f *Fetcher) LastResult() state.Entry {
+
+	f.resultMu.Lock()
+	defer f.resultMu.Unlock()
 
-	f.logger.Info("Fetching public IP", "url", f.config.APIURL)
+	return f.lastResult
+}
+
+// run performs a single fetch and update, filling in run as it goes.
+func (f *Fetcher) run(ctx context.Context, run *state.Entry) error {
 
 	// Fetch current IP
-	newIP, err := f.fetchCurrentIP()
+	newIP, err := f.fetchCurrentIP(ctx)
 	if err != nil {
 		f.logger.Error("Failed to fetch IP", "error", err)
 		return err
 	}
+	run.IP = newIP
 
-	// Read last IP from log file
-	lastIP, err := f.readLastIP()
+	// Read last IP of the same family from the state store
+	last, err := f.readLastIP(state.FamilyOf(newIP))
 	if err != nil {
 		f.logger.Warn("Failed to read last IP, treating as first run", "error", err)
 	}
-
-	// Append new IP to log file
-	if err := f.appendIP(newIP); err != nil {
-		f.logger.Error("Failed to append IP", "error", err)
-		return err
-	}
+	lastIP := last.IP
+	run.PreviousIP = lastIP
 
 	// Check if IP has changed or is first run
-	if lastIP == "" || lastIP != newIP {
-		f.logger.Info("IP changed or first run", "last_ip", lastIP, "new_ip", newIP)
+	run.Changed = lastIP == "" || lastIP != newIP
+	run.Forced = !run.Changed && Forced(ctx)
+	if run.Changed || run.Forced {
+		if run.Changed {
+			f.logger.Info("IP changed or first run", "last_ip", lastIP, "new_ip", newIP)
+		} else {
+			f.logger.Info("IP unchanged, forcing DNS update", "ip", newIP)
+		}
 
-		if err := f.updateZonomiDNS(newIP); err != nil {
+		run.Hosts, err = f.updateZonomiDNS(ctx, newIP)
+		if err != nil {
 			f.logger.Error("Failed to update Zonomi DNS", "error", err)
 			return err
 		}
@@ -105,12 +235,37 @@ func (f *Fetcher) FetchIP(_ context.Context) error {
 	return nil
 }
 
+// record appends run to the history, unless only changes and heartbeats are kept.
+func (f *Fetcher) record(run state.Entry) error {
+
+	// Changes and failures are always recorded, so only look up the last
+	// entry when the run might be skipped
+	if f.config.IPLogChangesOnly && !run.Changed && !run.Forced && run.Error == "" {
+		last, err := f.readLastIP(state.FamilyOf(run.IP))
+		if err == nil && !f.shouldRecord(last, run, time.Now()) {
+			return nil
+		}
+	}
+
+	if err := f.appendEntry(run); err != nil {
+		f.logger.Error("Failed to append IP", "error", err)
+		return err
+	}
+
+	return nil
+}
+
 // fetchCurrentIP retrieves the current public IP from the ipify API.
-func (f *Fetcher) fetchCurrentIP() (string, error) {
+func (f *Fetcher) fetchCurrentIP(ctx context.Context) (string, error) {
 
 	var ipResp IPResponse
 	operation := func() error {
-		resp, err := f.client.Get(f.config.APIURL)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.config.APIURL, nil)
+		if err != nil {
+			return backoff.Permanent(fmt.Errorf("failed to create request: %w", err))
+		}
+
+		resp, err := f.do(APIIPSource, req)
 		if err != nil {
 			return fmt.Errorf("HTTP request failed: %w", err)
 		}
@@ -128,9 +283,10 @@ func (f *Fetcher) fetchCurrentIP() (string, error) {
 		return json.Unmarshal(body, &ipResp)
 	}
 
-	err := backoff.RetryNotify(operation, backoff.WithMaxRetries(f.retryBackoff, uint64(f.config.MaxRetries)),
+	err := backoff.RetryNotify(operation, f.retryPolicy(ctx),
 		func(err error, duration time.Duration) {
 			f.logger.Warn("Retrying IP fetch", "error", err, "retry_after", duration)
+			f.metrics.Retry(APIIPSource)
 		})
 	if err != nil {
 		return "", err
@@ -139,54 +295,56 @@ func (f *Fetcher) fetchCurrentIP() (string, error) {
 	return ipResp.IP, nil
 }
 
-// readLastIP reads the last IP from the log file
-func (f *Fetcher) readLastIP() (string, error) {
+// readLastIP reads the last recorded entry of family from the state store
+func (f *Fetcher) readLastIP(family state.Family) (state.Entry, error) {
+	return f.store.LastEntry(family)
+}
 
-	file, err := os.Open(f.config.OutputFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return "", nil
-		}
+// shouldRecord reports whether run is written to the history. With
+// IP_LOG_CHANGES_ONLY, a successful run that found the IP unchanged is only
+// written once IP_LOG_HEARTBEAT has passed since the last entry.
+func (f *Fetcher) shouldRecord(last state.Entry, run state.Entry, now time.Time) bool {
 
-		return "", fmt.Errorf("failed to open log file: %w", err)
+	if !f.config.IPLogChangesOnly || run.Changed || run.Forced || run.Error != "" || last.IP != run.IP {
+		return true
 	}
-	defer file.Close()
 
-	var lastIP string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		var lastEntry IPLogEntry
-		if err := json.Unmarshal([]byte(scanner.Text()), &lastEntry); err == nil {
-			lastIP = lastEntry.IP
-		}
+	if f.config.IPLogHeartbeat <= 0 {
+		return false
 	}
 
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("failed to read log file: %w", err)
+	at, err := time.Parse(time.RFC3339, last.Timestamp)
+	if err != nil {
+		return true
 	}
 
-	return lastIP, nil
+	return now.Sub(at) >= f.config.IPLogHeartbeat
 }
 
-// updateZonomiDNS calls the DNS update API for each host
-func (f *Fetcher) updateZonomiDNS(ip string) error {
+// updateZonomiDNS calls the DNS update API for each host and returns the
+// outcome for every host
+func (f *Fetcher) updateZonomiDNS(ctx context.Context, ip string) ([]state.HostResult, error) {
 
 	var errs []error
+	results := make([]state.HostResult, 0, len(f.config.ZonomiHosts))
 	for _, host := range f.config.ZonomiHosts {
-		query := url.Values{}
-		query.Set("name", host)
-		query.Set("value", ip)
-		query.Set("type", "A")
-		query.Set("api_key", f.config.ZonomiAPIKey)
-
-		urlStr := f.config.ZonomiAPIURL + "?" + query.Encode()
+		operation := func() error {
 
-		f.logger.Info("Calling Zonomi API", "host", host, "url", urlStr)
+			// Build the request on every attempt, as a POST body can only be read once
+			req, err := f.dnsRequests.NewRequest(ctx, host, ip)
+			if err != nil {
+				return backoff.Permanent(err)
+			}
 
-		operation := func() error {
+			f.logger.Info("Calling Zonomi API", "host", host, "method", req.Method, "url", logging.Redact(req.URL.String()))
 
-			resp, err := f.client.Get(urlStr)
+			resp, err := f.do(APIZonomi, req)
 			if err != nil {
+				// Keep the API key out of the error, which is logged upstream
+				var urlErr *url.Error
+				if errors.As(err, &urlErr) {
+					urlErr.URL = logging.Redact(urlErr.URL)
+				}
 				return fmt.Errorf("Zonomi API request failed: %w", err)
 			}
 			defer resp.Body.Close()
@@ -199,45 +357,47 @@ func (f *Fetcher) updateZonomiDNS(ip string) error {
 			return nil
 		}
 
-		err := backoff.RetryNotify(operation, backoff.WithMaxRetries(f.retryBackoff, uint64(f.config.MaxRetries)),
+		err := backoff.RetryNotify(operation, f.retryPolicy(ctx),
 			func(err error, d time.Duration) {
 				f.logger.Warn("Retrying Zonomi API", "host", host, "error", err, "retry_after", d)
+				f.metrics.Retry(APIZonomi)
 			})
 		if err != nil {
 			errs = append(errs, fmt.Errorf("failed for host %s: %w", host, err))
+			results = append(results, state.HostResult{Host: host, Value: ip, Error: logging.Redact(err.Error())})
+			continue
+		}
+		results = append(results, state.HostResult{Host: host, Value: ip, OK: true})
+
+		// Remember what each host points at
+		if err := f.store.SetHostValue(host, ip, time.Now()); err != nil {
+			f.logger.Warn("Failed to record host value", "host", host, "error", err)
 		}
 	}
 
 	if len(errs) > 0 {
-		return fmt.Errorf("errors updating hosts: %v", errs)
+		return results, fmt.Errorf("errors updating hosts: %v", errs)
 	}
 
-	return nil
+	return results, nil
 }
 
-// appendIP appends the IP and timestamp to the output file
-func (f *Fetcher) appendIP(ip string) error {
+// do sends req, reporting its latency as a request to api.
+func (f *Fetcher) do(api string, req *http.Request) (*http.Response, error) {
 
-	// Open or crate the file if needed
-	file, err := os.OpenFile(f.config.OutputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
+	start := time.Now()
+	resp, err := f.client.Do(req)
+	f.metrics.ObserveRequest(api, time.Since(start))
 
-	entry := IPLogEntry{
-		IP:        ip,
-		Timestamp: time.Now().Format(time.RFC3339),
-	}
-
-	jsonData, err := json.Marshal(entry)
-	if err != nil {
-		return fmt.Errorf("failed to marshal JSON: %w", err)
-	}
+	return resp, err
+}
 
-	if _, err := file.WriteString(string(jsonData) + "\n"); err != nil {
-		return fmt.Errorf("failed to write to file: %w", err)
-	}
+// retryPolicy limits retries to MAX_RETRIES and stops them once ctx is done.
+func (f *Fetcher) retryPolicy(ctx context.Context) backoff.BackOff {
+	return backoff.WithContext(backoff.WithMaxRetries(f.retryBackoff, uint64(f.config.MaxRetries)), ctx)
+}
 
-	return nil
+// appendEntry records a run in the state store
+func (f *Fetcher) appendEntry(entry state.Entry) error {
+	return f.store.Record(entry)
 }
@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
@@ -8,10 +9,13 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/Drakx/ZonoCaller/internal/logging"
+	"github.com/Drakx/ZonoCaller/internal/state"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -141,6 +145,52 @@ func TestFetchIP_NoIPChange(t *testing.T) {
 	assert.Equal(t, "192.168.1.1", lastEntry.IP)
 }
 
+func TestFetchIP_Forced(t *testing.T) {
+
+	// Mock ipify server
+	ipifyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"ip":"192.168.1.1"}`))
+	}))
+	defer ipifyServer.Close()
+
+	// Mock Zonomi server
+	var zonomiCalls atomic.Int32
+	zonomiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zonomiCalls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer zonomiServer.Close()
+
+	cfg := config.Config{
+		APIURL:           ipifyServer.URL,
+		ZonomiAPIURL:     zonomiServer.URL,
+		OutputFile:       filepath.Join(t.TempDir(), "ip_log.txt"),
+		ZonomiHosts:      []string{"test.host"},
+		ZonomiAPIKey:     "test-key",
+		IPLogChangesOnly: true,
+	}
+
+	f := New(cfg)
+	require.NoError(t, f.FetchIP(context.Background()))
+	require.Equal(t, int32(1), zonomiCalls.Load())
+
+	// Forcing pushes the unchanged IP again and is always recorded
+	require.NoError(t, f.FetchIP(ForceUpdate(context.Background())))
+	assert.Equal(t, int32(2), zonomiCalls.Load())
+
+	result := f.LastResult()
+	assert.False(t, result.Changed)
+	assert.True(t, result.Forced)
+	require.Len(t, result.Hosts, 1)
+	assert.True(t, result.Hosts[0].OK)
+
+	entries, err := f.store.History()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.True(t, entries[1].Forced)
+}
+
 func TestFetchIP_IPChange(t *testing.T) {
 
 	// Mock ipify server
@@ -193,6 +243,82 @@ func TestFetchIP_IPChange(t *testing.T) {
 	err = json.Unmarshal([]byte(lines[len(lines)-2]), &lastEntry) // Last line is empty
 	require.NoError(t, err)
 	assert.Equal(t, "192.168.1.2", lastEntry.IP)
+
+	// The run is recorded with its outcome
+	assert.Equal(t, state.EntryVersion, lastEntry.Version)
+	assert.NotEmpty(t, lastEntry.RunID)
+	assert.Equal(t, "192.168.1.1", lastEntry.PreviousIP)
+	assert.True(t, lastEntry.Changed)
+	assert.Equal(t, []string{ipifyServer.URL}, lastEntry.Sources)
+	assert.Equal(t, []state.HostResult{
+		{Host: "test.host1", Value: "192.168.1.2", OK: true},
+		{Host: "test.host2", Value: "192.168.1.2", OK: true},
+	}, lastEntry.Hosts)
+	assert.Empty(t, lastEntry.Error)
+}
+
+func TestFetchIP_RecordsFailures(t *testing.T) {
+
+	// Mock ipify server
+	ipifyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"ip":"192.168.1.2"}`))
+	}))
+	defer ipifyServer.Close()
+
+	// Mock Zonomi server that rejects one host
+	zonomiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") == "bad.host" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer zonomiServer.Close()
+
+	// Config
+	cfg := config.Config{
+		APIURL:           ipifyServer.URL,
+		ZonomiAPIURL:     zonomiServer.URL,
+		OutputFile:       filepath.Join(t.TempDir(), "ip_log.txt"),
+		ZonomiHosts:      []string{"good.host", "bad.host"},
+		ZonomiAPIKey:     "test-key",
+		IPLogChangesOnly: true,
+	}
+
+	f := New(cfg)
+
+	// Run FetchIP
+	err := f.FetchIP(context.Background())
+	require.Error(t, err)
+
+	entries, err := f.store.History()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "192.168.1.2", entries[0].IP)
+	assert.Contains(t, entries[0].Error, "errors updating hosts")
+	require.Len(t, entries[0].Hosts, 2)
+	assert.True(t, entries[0].Hosts[0].OK)
+	assert.False(t, entries[0].Hosts[1].OK)
+	assert.Contains(t, entries[0].Hosts[1].Error, "400 Bad Request")
+
+	// A failed fetch is recorded too
+	ipifyServer.Close()
+	require.Error(t, f.FetchIP(context.Background()))
+
+	entries, err = f.store.History()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Empty(t, entries[1].IP)
+	assert.Contains(t, entries[1].Error, "HTTP request failed")
+
+	// The outcome is reported even though the log only keeps changes
+	assert.Equal(t, entries[1], f.LastResult())
+
+	// Failed runs still count as runs for catching up
+	lastRun, err := f.store.LastRun()
+	require.NoError(t, err)
+	assert.Equal(t, entries[1].Timestamp, lastRun.Format(time.RFC3339))
 }
 
 func TestFetchIP_MultipleHosts(t *testing.T) {
@@ -268,9 +394,9 @@ func TestReadLastIP_EmptyFile(t *testing.T) {
 	f := New(cfg)
 
 	// Read last IP
-	ip, err := f.readLastIP()
+	entry, err := f.readLastIP(state.FamilyIPv4)
 	require.NoError(t, err)
-	assert.Empty(t, ip, "Empty file should return empty IP")
+	assert.Empty(t, entry.IP, "Empty file should return empty IP")
 }
 
 func TestReadLastIP_ValidFile(t *testing.T) {
@@ -294,9 +420,9 @@ func TestReadLastIP_ValidFile(t *testing.T) {
 	f := New(cfg)
 
 	// Read last IP
-	ip, err := f.readLastIP()
+	last, err := f.readLastIP(state.FamilyIPv4)
 	require.NoError(t, err)
-	assert.Equal(t, "192.168.1.1", ip)
+	assert.Equal(t, "192.168.1.1", last.IP)
 }
 
 func TestReadLastIP_NonExistentFile(t *testing.T) {
@@ -315,9 +441,9 @@ func TestReadLastIP_NonExistentFile(t *testing.T) {
 	f := New(cfg)
 
 	// Read last IP
-	ip, err := f.readLastIP()
+	entry, err := f.readLastIP(state.FamilyIPv4)
 	require.NoError(t, err)
-	assert.Empty(t, ip, "Non-existent file should return empty IP")
+	assert.Empty(t, entry.IP, "Non-existent file should return empty IP")
 }
 
 func TestUpdateZonomiDNS_Success(t *testing.T) {
@@ -343,7 +469,7 @@ func TestUpdateZonomiDNS_Success(t *testing.T) {
 	f := New(cfg)
 
 	// Update DNS
-	err := f.updateZonomiDNS("192.168.1.1")
+	_, err := f.updateZonomiDNS(context.Background(), "192.168.1.1")
 	require.NoError(t, err)
 }
 
@@ -367,11 +493,111 @@ func TestUpdateZonomiDNS_Failure(t *testing.T) {
 	f := New(cfg)
 
 	// Update DNS
-	err := f.updateZonomiDNS("192.168.1.1")
+	_, err := f.updateZonomiDNS(context.Background(), "192.168.1.1")
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), `errors updating hosts`)
 }
 
+func TestUpdateZonomiDNS_RecordsHostValues(t *testing.T) {
+
+	// Mock Zonomi server that rejects one host
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") == "bad.host" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// Config
+	cfg := config.Config{
+		ZonomiAPIURL: server.URL,
+		ZonomiHosts:  []string{"good.host", "bad.host"},
+		ZonomiAPIKey: "test-key",
+	}
+
+	store, err := state.OpenBoltStore(filepath.Join(t.TempDir(), "state.db"), state.Retention{})
+	require.NoError(t, err)
+	defer store.Close()
+
+	f := New(cfg, WithStore(store))
+
+	// Update DNS
+	_, err = f.updateZonomiDNS(context.Background(), "192.168.1.1")
+	require.Error(t, err)
+
+	value, err := store.HostValue("good.host")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.1", value)
+
+	value, err = store.HostValue("bad.host")
+	require.NoError(t, err)
+	assert.Empty(t, value)
+}
+
+func TestUpdateZonomiDNS_RedactsAPIKey(t *testing.T) {
+
+	// Closed server so the request fails with the URL in the error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	// Config
+	cfg := config.Config{
+		ZonomiAPIURL: server.URL,
+		ZonomiHosts:  []string{"test.host"},
+		ZonomiAPIKey: "super-secret-key",
+		MaxRetries:   1,
+	}
+
+	var buf bytes.Buffer
+	f := New(cfg)
+	f.logger = logging.New(&buf)
+
+	// Update DNS
+	_, err := f.updateZonomiDNS(context.Background(), "192.168.1.1")
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "super-secret-key")
+	assert.NotContains(t, buf.String(), "super-secret-key")
+	assert.Contains(t, buf.String(), "api_key=[REDACTED]")
+}
+
+func TestFetchIP_RedactsRunError(t *testing.T) {
+
+	ipifyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ip":"192.168.1.1"}`))
+	}))
+	defer ipifyServer.Close()
+
+	// Zonomi echoes the request URL in its error page
+	zonomiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad request: " + r.URL.String()))
+	}))
+	defer zonomiServer.Close()
+
+	outputFile := filepath.Join(t.TempDir(), "ip_log.txt")
+	cfg := config.Config{
+		APIURL:       ipifyServer.URL,
+		ZonomiAPIURL: zonomiServer.URL,
+		OutputFile:   outputFile,
+		ZonomiHosts:  []string{"test.host"},
+		ZonomiAPIKey: "super-secret-key",
+	}
+
+	f := New(cfg)
+	require.Error(t, f.FetchIP(context.Background()))
+
+	// The persisted record follows the same redaction as the logs
+	result := f.LastResult()
+	assert.Contains(t, result.Error, "api_key=[REDACTED]")
+	assert.NotContains(t, result.Error, "super-secret-key")
+
+	data, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "super-secret-key")
+}
+
 func TestUpdateZonomiDNS_Retry(t *testing.T) {
 
 	// Mock Zonomi server with retryable failure
@@ -398,7 +624,7 @@ func TestUpdateZonomiDNS_Retry(t *testing.T) {
 	f := New(cfg)
 
 	// Update DNS
-	err := f.updateZonomiDNS("192.168.1.1")
+	_, err := f.updateZonomiDNS(context.Background(), "192.168.1.1")
 	require.NoError(t, err)
 	assert.Equal(t, 2, attempts, "Should retry once before succeeding")
 }
@@ -420,7 +646,7 @@ func TestAppendIP(t *testing.T) {
 	f := New(cfg)
 
 	// Append IP
-	err := f.appendIP("192.168.1.1")
+	err := f.appendEntry(IPLogEntry{IP: "192.168.1.1", Timestamp: time.Now().Format(time.RFC3339)})
 	require.NoError(t, err)
 
 	// Check file contents
@@ -433,3 +659,125 @@ func TestAppendIP(t *testing.T) {
 	assert.Equal(t, "192.168.1.1", entry.IP)
 	assert.NotEmpty(t, entry.Timestamp)
 }
+
+func TestShouldRecord(t *testing.T) {
+
+	now := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	last := state.Entry{IP: "192.168.1.1", Timestamp: now.Add(-2 * time.Hour).Format(time.RFC3339)}
+
+	tests := []struct {
+		name        string
+		changesOnly bool
+		heartbeat   time.Duration
+		ip          string
+		expected    bool
+	}{
+		{name: "Every run", ip: "192.168.1.1", expected: true},
+		{name: "Changes only, changed", changesOnly: true, ip: "192.168.1.2", expected: true},
+		{name: "Changes only, unchanged", changesOnly: true, ip: "192.168.1.1", expected: false},
+		{name: "Heartbeat due", changesOnly: true, heartbeat: time.Hour, ip: "192.168.1.1", expected: true},
+		{name: "Heartbeat not due", changesOnly: true, heartbeat: 24 * time.Hour, ip: "192.168.1.1", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(config.Config{IPLogChangesOnly: tt.changesOnly, IPLogHeartbeat: tt.heartbeat})
+			assert.Equal(t, tt.expected, f.shouldRecord(last, state.Entry{IP: tt.ip, Changed: tt.ip != last.IP}, now))
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+
+	cfg := config.Config{
+		ZonomiAPIURL: "https://zonomi.com/app/dns/dyndns.jsp",
+		ZonomiHosts:  []string{"old.host"},
+		ZonomiAPIKey: "test-key",
+	}
+
+	f := New(cfg)
+
+	// Reload with new hosts
+	cfg.ZonomiHosts = []string{"new.host"}
+	f.Reload(cfg)
+
+	assert.Equal(t, []string{"new.host"}, f.config.ZonomiHosts)
+}
+
+func TestFetchIP_ContextDeadline(t *testing.T) {
+
+	// Mock ipify server that never answers in time
+	ipifyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ipifyServer.Close()
+
+	cfg := config.Config{
+		APIURL:       ipifyServer.URL,
+		OutputFile:   filepath.Join(t.TempDir(), "ip_log.txt"),
+		ZonomiHosts:  []string{"test.host"},
+		ZonomiAPIKey: "test-key",
+		MaxRetries:   3,
+	}
+
+	f := New(cfg)
+
+	// The deadline cancels the request and stops further retries
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := f.FetchIP(ctx)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	entries, err := f.store.History()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Contains(t, entries[0].Error, "context deadline exceeded")
+}
+
+// recordingMetrics counts requests and retries by API.
+type recordingMetrics struct {
+	requests map[string]int
+	retries  map[string]int
+}
+
+func (m *recordingMetrics) ObserveRequest(api string, _ time.Duration) { m.requests[api]++ }
+func (m *recordingMetrics) Retry(api string)                           { m.retries[api]++ }
+
+func TestFetchIP_Metrics(t *testing.T) {
+
+	// The first IP request fails and is retried
+	var ipCalls atomic.Int32
+	ipifyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ipCalls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"ip":"192.168.1.1"}`))
+	}))
+	defer ipifyServer.Close()
+
+	zonomiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer zonomiServer.Close()
+
+	cfg := config.Config{
+		APIURL:       ipifyServer.URL,
+		ZonomiAPIURL: zonomiServer.URL,
+		OutputFile:   filepath.Join(t.TempDir(), "ip_log.txt"),
+		MaxRetries:   1,
+		ZonomiHosts:  []string{"test.host1", "test.host2"},
+		ZonomiAPIKey: "test-key",
+	}
+
+	metrics := &recordingMetrics{requests: map[string]int{}, retries: map[string]int{}}
+	f := New(cfg, WithMetrics(metrics))
+
+	require.NoError(t, f.FetchIP(context.Background()))
+	assert.Equal(t, map[string]int{APIIPSource: 2, APIZonomi: 2}, metrics.requests)
+	assert.Equal(t, map[string]int{APIIPSource: 1}, metrics.retries)
+}
@@ -0,0 +1,61 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+)
+
+// DNSRequestBuilder builds the HTTP request that points host at ip. It is the
+// one place provider credentials are attached to outgoing requests.
+type DNSRequestBuilder interface {
+	NewRequest(ctx context.Context, host, ip string) (*http.Request, error)
+}
+
+// zonomiRequestBuilder builds requests for the Zonomi dyndns API.
+type zonomiRequestBuilder struct {
+	apiURL   string
+	apiKey   config.Secret
+	authMode string
+}
+
+// newZonomiRequestBuilder creates a request builder from the Zonomi settings in cfg.
+func newZonomiRequestBuilder(cfg config.Config) *zonomiRequestBuilder {
+	return &zonomiRequestBuilder{
+		apiURL:   cfg.ZonomiAPIURL,
+		apiKey:   cfg.ZonomiAPIKey,
+		authMode: cfg.ZonomiAuthMode,
+	}
+}
+
+// NewRequest builds an A record update for host. The API key is sent in a
+// POST form body in post mode, and as a query parameter otherwise.
+func (b *zonomiRequestBuilder) NewRequest(ctx context.Context, host, ip string) (*http.Request, error) {
+
+	params := url.Values{}
+	params.Set("name", host)
+	params.Set("value", ip)
+	params.Set("type", "A")
+	params.Set("api_key", b.apiKey.Reveal())
+
+	if b.authMode == config.AuthModePost {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.apiURL, strings.NewReader(params.Encode()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Zonomi request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		return req, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.apiURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Zonomi request: %w", err)
+	}
+
+	return req, nil
+}
@@ -0,0 +1,89 @@
+package fetcher
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZonomiRequestBuilder_Query(t *testing.T) {
+
+	b := newZonomiRequestBuilder(config.Config{
+		ZonomiAPIURL:   "https://zonomi.com/app/dns/dyndns.jsp",
+		ZonomiAPIKey:   "test-key",
+		ZonomiAuthMode: config.AuthModeQuery,
+	})
+
+	req, err := b.NewRequest(context.Background(), "test.host", "192.168.1.1")
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodGet, req.Method)
+	assert.Equal(t, "test-key", req.URL.Query().Get("api_key"))
+	assert.Equal(t, "test.host", req.URL.Query().Get("name"))
+	assert.Equal(t, "192.168.1.1", req.URL.Query().Get("value"))
+	assert.Equal(t, "A", req.URL.Query().Get("type"))
+}
+
+func TestZonomiRequestBuilder_Post(t *testing.T) {
+
+	b := newZonomiRequestBuilder(config.Config{
+		ZonomiAPIURL:   "https://zonomi.com/app/dns/dyndns.jsp",
+		ZonomiAPIKey:   "test-key",
+		ZonomiAuthMode: config.AuthModePost,
+	})
+
+	req, err := b.NewRequest(context.Background(), "test.host", "192.168.1.1")
+	require.NoError(t, err)
+
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, "https://zonomi.com/app/dns/dyndns.jsp", req.URL.String())
+	assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	form, err := url.ParseQuery(string(body))
+	require.NoError(t, err)
+	assert.Equal(t, "test-key", form.Get("api_key"))
+	assert.Equal(t, "test.host", form.Get("name"))
+}
+
+func TestUpdateZonomiDNS_PostRetry(t *testing.T) {
+
+	// Mock Zonomi server that fails once, then checks the form is intact
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Empty(t, r.URL.RawQuery)
+		assert.Equal(t, "test-key", r.FormValue("api_key"))
+		if attempts < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// Config
+	cfg := config.Config{
+		ZonomiAPIURL:   server.URL,
+		ZonomiHosts:    []string{"test.host"},
+		ZonomiAPIKey:   "test-key",
+		ZonomiAuthMode: config.AuthModePost,
+		MaxRetries:     2,
+	}
+
+	f := New(cfg)
+
+	// Update DNS
+	_, err := f.updateZonomiDNS(context.Background(), "192.168.1.1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+}
@@ -0,0 +1,225 @@
+// Package health tracks run outcomes and reports whether the service is
+// healthy, for the liveness, readiness and health endpoints.
+package health
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/Drakx/ZonoCaller/internal/logging"
+	"github.com/Drakx/ZonoCaller/internal/state"
+)
+
+// Overall statuses of a Report.
+const (
+	StatusHealthy   = "healthy"
+	StatusUnhealthy = "unhealthy"
+)
+
+// Statuses of a single DNS host.
+const (
+	HostOK      = "ok"
+	HostFailed  = "failed"
+	HostUnknown = "unknown"
+)
+
+// Scheduler is the part of scheduler.Scheduler the checks use.
+type Scheduler interface {
+	Running() bool
+	NextRuns() []time.Time
+}
+
+// Report is the health of the service.
+type Report struct {
+	Status string `json:"status"`
+	// Problems lists why the service is unhealthy
+	Problems            []string     `json:"problems,omitempty"`
+	Running             bool         `json:"running"`
+	LastSuccess         *time.Time   `json:"last_success,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time   `json:"last_error_at,omitempty"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	NextRun             *time.Time   `json:"next_run,omitempty"`
+	Hosts               []HostStatus `json:"hosts"`
+	ReloadError         string       `json:"reload_error,omitempty"`
+}
+
+// HostStatus is the outcome of the last DNS update of a host. Hosts are
+// only updated when the IP changes, so a host may have no outcome yet.
+type HostStatus struct {
+	Host        string     `json:"host"`
+	Status      string     `json:"status"`
+	Value       string     `json:"value,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	LastUpdate  *time.Time `json:"last_update,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+}
+
+// Monitor records run outcomes and configuration reloads.
+type Monitor struct {
+	mu          sync.Mutex
+	maxFailures int
+	maxAge      time.Duration
+	hostNames   []string
+	started     time.Time
+
+	lastSuccess time.Time
+	lastError   string
+	lastErrorAt time.Time
+	failures    int
+	hosts       map[string]HostStatus
+	reloadError string
+}
+
+// New creates a Monitor with the thresholds and hosts in cfg.
+func New(cfg config.Config) *Monitor {
+
+	m := &Monitor{started: time.Now(), hosts: make(map[string]HostStatus)}
+	m.Reload(cfg)
+
+	return m
+}
+
+// Reload swaps in new thresholds and hosts, and clears a previous reload
+// failure.
+func (m *Monitor) Reload(cfg config.Config) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.maxFailures = cfg.HealthMaxFailures
+	m.maxAge = cfg.HealthMaxAge
+	m.hostNames = slices.Clone(cfg.ZonomiHosts)
+	m.reloadError = ""
+}
+
+// ReloadFailed records that reloading the configuration failed, so the
+// service runs on a configuration that no longer matches its source. Secrets
+// in err are masked, since it is served on the unauthenticated endpoints.
+func (m *Monitor) ReloadFailed(err error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reloadError = logging.Redact(err.Error())
+}
+
+// RecordRun records the outcome of a run, masking secrets in err. It is meant
+// to be passed to scheduler.WithRunObserver.
+func (m *Monitor) RecordRun(result state.Entry, err error) {
+
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		m.failures++
+		m.lastError = logging.Redact(err.Error())
+		m.lastErrorAt = now
+	} else {
+		m.failures = 0
+		m.lastSuccess = now
+	}
+
+	for _, host := range result.Hosts {
+		status := m.hosts[host.Host]
+		status.Host = host.Host
+		status.Value = host.Value
+		status.Error = host.Error
+		status.LastUpdate = &now
+		status.Status = HostFailed
+		if host.OK {
+			status.Status = HostOK
+			status.LastSuccess = &now
+		}
+		m.hosts[host.Host] = status
+	}
+}
+
+// Report returns the health of the service at now. The service is unhealthy
+// if the scheduler is not running, the last configuration reload failed,
+// HEALTH_MAX_FAILURES runs in a row failed, or no run has succeeded within
+// HEALTH_MAX_AGE.
+func (m *Monitor) Report(s Scheduler, now time.Time) Report {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report := Report{
+		Running:             s.Running(),
+		LastError:           m.lastError,
+		ConsecutiveFailures: m.failures,
+		ReloadError:         m.reloadError,
+		Hosts:               make([]HostStatus, 0, len(m.hostNames)),
+	}
+
+	if lastSuccess := m.lastSuccess; !lastSuccess.IsZero() {
+		report.LastSuccess = &lastSuccess
+	}
+	if lastErrorAt := m.lastErrorAt; !lastErrorAt.IsZero() {
+		report.LastErrorAt = &lastErrorAt
+	}
+	if runs := s.NextRuns(); len(runs) > 0 {
+		report.NextRun = &runs[0]
+	}
+
+	for _, host := range m.hostNames {
+		status, ok := m.hosts[host]
+		if !ok {
+			status = HostStatus{Host: host, Status: HostUnknown}
+		}
+		report.Hosts = append(report.Hosts, status)
+	}
+
+	if !report.Running {
+		report.Problems = append(report.Problems, "scheduler is not running")
+	}
+
+	if m.reloadError != "" {
+		report.Problems = append(report.Problems, "configuration reload failed: "+m.reloadError)
+	}
+
+	if m.maxFailures > 0 && m.failures >= m.maxFailures {
+		report.Problems = append(report.Problems, fmt.Sprintf("%d consecutive runs failed", m.failures))
+	}
+
+	// Before the first success, allow HEALTH_MAX_AGE from startup
+	if m.maxAge > 0 {
+		since := m.lastSuccess
+		if since.IsZero() {
+			since = m.started
+		}
+		if age := now.Sub(since); age > m.maxAge {
+			report.Problems = append(report.Problems, fmt.Sprintf("no successful run for %s", age.Round(time.Second)))
+		}
+	}
+
+	report.Status = StatusHealthy
+	if len(report.Problems) > 0 {
+		report.Status = StatusUnhealthy
+	}
+
+	return report
+}
+
+// Ready returns why the service is not ready, or nil: the scheduler must be
+// running on a configuration that loaded successfully.
+func (m *Monitor) Ready(s Scheduler) error {
+
+	if !s.Running() {
+		return fmt.Errorf("scheduler is not running")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.reloadError != "" {
+		return fmt.Errorf("configuration reload failed: %s", m.reloadError)
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/Drakx/ZonoCaller/internal/logging"
+	"github.com/Drakx/ZonoCaller/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeScheduler reports a fixed state.
+type fakeScheduler struct {
+	running  bool
+	nextRuns []time.Time
+}
+
+func (f *fakeScheduler) Running() bool         { return f.running }
+func (f *fakeScheduler) NextRuns() []time.Time { return f.nextRuns }
+
+func TestMonitor_Report(t *testing.T) {
+	cfg := config.Config{ZonomiHosts: []string{"a.example.com", "b.example.com"}, HealthMaxFailures: 2}
+	m := New(cfg)
+	next := time.Date(2025, 8, 30, 23, 59, 0, 0, time.UTC)
+	s := &fakeScheduler{running: true, nextRuns: []time.Time{next}}
+
+	// Nothing has run yet
+	report := m.Report(s, time.Now())
+	assert.Equal(t, StatusHealthy, report.Status)
+	assert.Nil(t, report.LastSuccess)
+	assert.Equal(t, &next, report.NextRun)
+	assert.Equal(t, []HostStatus{
+		{Host: "a.example.com", Status: HostUnknown},
+		{Host: "b.example.com", Status: HostUnknown},
+	}, report.Hosts)
+
+	// A run that updated one host and failed the other
+	m.RecordRun(state.Entry{Hosts: []state.HostResult{
+		{Host: "a.example.com", Value: "192.168.1.1", OK: true},
+		{Host: "b.example.com", Value: "192.168.1.1", Error: "HTTP 500"},
+	}}, errors.New("failed to update b.example.com"))
+
+	report = m.Report(s, time.Now())
+	assert.Equal(t, StatusHealthy, report.Status)
+	assert.Equal(t, 1, report.ConsecutiveFailures)
+	assert.Equal(t, "failed to update b.example.com", report.LastError)
+	require.NotNil(t, report.LastErrorAt)
+	assert.Equal(t, HostOK, report.Hosts[0].Status)
+	assert.NotNil(t, report.Hosts[0].LastSuccess)
+	assert.Equal(t, HostFailed, report.Hosts[1].Status)
+	assert.Equal(t, "HTTP 500", report.Hosts[1].Error)
+	assert.Nil(t, report.Hosts[1].LastSuccess)
+
+	// HEALTH_MAX_FAILURES failures in a row are unhealthy
+	m.RecordRun(state.Entry{}, errors.New("HTTP request failed"))
+	report = m.Report(s, time.Now())
+	assert.Equal(t, StatusUnhealthy, report.Status)
+	assert.Equal(t, []string{"2 consecutive runs failed"}, report.Problems)
+
+	// A success resets the count
+	m.RecordRun(state.Entry{}, nil)
+	report = m.Report(s, time.Now())
+	assert.Equal(t, StatusHealthy, report.Status)
+	assert.Zero(t, report.ConsecutiveFailures)
+	require.NotNil(t, report.LastSuccess)
+	assert.Equal(t, "HTTP request failed", report.LastError)
+
+	// A stopped scheduler is unhealthy
+	s.running = false
+	report = m.Report(s, time.Now())
+	assert.Equal(t, StatusUnhealthy, report.Status)
+	assert.Equal(t, []string{"scheduler is not running"}, report.Problems)
+}
+
+func TestMonitor_RedactsErrors(t *testing.T) {
+	m := New(config.Config{})
+	s := &fakeScheduler{running: true}
+
+	m.RecordRun(state.Entry{}, errors.New(`Get "https://zonomi.com/app/dns/dyndns.jsp?host=a.example.com&api_key=secret": timeout`))
+	m.ReloadFailed(errors.New("failed to call https://api.example.com/ip?token=secret"))
+
+	report := m.Report(s, time.Now())
+	assert.NotContains(t, report.LastError, "secret")
+	assert.Contains(t, report.LastError, "api_key="+logging.Redacted)
+	assert.NotContains(t, report.ReloadError, "secret")
+	assert.Contains(t, report.ReloadError, "token="+logging.Redacted)
+}
+
+func TestMonitor_MaxAge(t *testing.T) {
+	m := New(config.Config{HealthMaxAge: time.Hour})
+	s := &fakeScheduler{running: true}
+
+	// Startup counts as the last success until a run succeeds
+	assert.Equal(t, StatusHealthy, m.Report(s, time.Now().Add(59*time.Minute)).Status)
+	report := m.Report(s, time.Now().Add(2*time.Hour))
+	assert.Equal(t, StatusUnhealthy, report.Status)
+	require.Len(t, report.Problems, 1)
+	assert.Contains(t, report.Problems[0], "no successful run for 2h0m")
+
+	m.RecordRun(state.Entry{}, nil)
+	assert.Equal(t, StatusHealthy, m.Report(s, time.Now().Add(59*time.Minute)).Status)
+}
+
+func TestHandlers(t *testing.T) {
+	m := New(config.Config{ZonomiHosts: []string{"a.example.com"}, HealthMaxFailures: 3})
+	s := &fakeScheduler{running: true}
+
+	rec := httptest.NewRecorder()
+	LivezHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	ReadyzHandler(m, s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	Handler(m, s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	// A failed reload makes the service unready and unhealthy until a
+	// reload succeeds
+	m.ReloadFailed(errors.New("failed to decrypt ZONOMI_API_KEY"))
+
+	rec = httptest.NewRecorder()
+	ReadyzHandler(m, s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "failed to decrypt ZONOMI_API_KEY")
+
+	rec = httptest.NewRecorder()
+	Handler(m, s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var report Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, StatusUnhealthy, report.Status)
+	assert.Equal(t, "failed to decrypt ZONOMI_API_KEY", report.ReloadError)
+
+	m.Reload(config.Config{ZonomiHosts: []string{"a.example.com"}})
+	rec = httptest.NewRecorder()
+	ReadyzHandler(m, s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// The scheduler must be running
+	s.running = false
+	rec = httptest.NewRecorder()
+	ReadyzHandler(m, s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
@@ -0,0 +1,49 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// LivezHandler responds 200 while the process is serving requests.
+func LivezHandler() http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// ReadyzHandler responds 200 when the service is ready, and 503 with the
+// reason otherwise.
+func ReadyzHandler(m *Monitor, s Scheduler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if err := m.Ready(s); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// Handler serves the health report as JSON, with status 200 when healthy
+// and 503 when not.
+func Handler(m *Monitor, s Scheduler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		report := m.Report(s, time.Now())
+
+		status := http.StatusOK
+		if report.Status != StatusHealthy {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	})
+}
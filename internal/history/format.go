@@ -0,0 +1,131 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Drakx/ZonoCaller/internal/state"
+)
+
+// Output formats accepted by Write.
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+// Report is the JSON form of a history query.
+type Report struct {
+	Entries []state.Entry `json:"entries"`
+	Summary Summary       `json:"summary"`
+}
+
+// Write writes entries and their summary to w in format. CSV output holds
+// the entries only.
+func Write(w io.Writer, format string, entries []state.Entry, summary Summary) error {
+
+	switch format {
+	case FormatTable:
+		return writeTable(w, entries, summary)
+	case FormatJSON:
+		if entries == nil {
+			entries = []state.Entry{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(Report{Entries: entries, Summary: summary})
+	case FormatCSV:
+		return writeCSV(w, entries)
+	default:
+		return fmt.Errorf("unknown format %q, expected table, json or csv", format)
+	}
+}
+
+// writeTable writes entries as aligned columns followed by the summary.
+func writeTable(w io.Writer, entries []state.Entry, summary Summary) error {
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tIP\tPREVIOUS\tCHANGED\tHOSTS\tERROR")
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			entry.Timestamp, orDash(entry.IP), orDash(entry.PreviousIP), yesNo(entry.Changed),
+			orDash(formatHosts(entry.Hosts)), orDash(entry.Error))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nRuns: %d, changes: %d, failed: %d\n", summary.Runs, summary.Changes, summary.Failed)
+	if summary.AverageIPLifetime > 0 {
+		fmt.Fprintf(w, "Average IP lifetime: %s\n", summary.AverageIPLifetime.String())
+	}
+
+	for _, month := range summary.ChangesPerMonth {
+		fmt.Fprintf(w, "  %s: %d\n", month.Month, month.Changes)
+	}
+
+	return nil
+}
+
+// writeCSV writes entries as CSV with a header row.
+func writeCSV(w io.Writer, entries []state.Entry) error {
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"timestamp", "ip", "previous_ip", "changed", "hosts", "error", "run_id", "duration_ms"})
+	for _, entry := range entries {
+		cw.Write([]string{
+			entry.Timestamp,
+			entry.IP,
+			entry.PreviousIP,
+			strconv.FormatBool(entry.Changed),
+			formatHosts(entry.Hosts),
+			entry.Error,
+			entry.RunID,
+			strconv.FormatInt(entry.DurationMS, 10),
+		})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// formatHosts lists host results as "host=ok" or "host=failed".
+func formatHosts(results []state.HostResult) string {
+
+	parts := make([]string, len(results))
+	for i, result := range results {
+		outcome := "ok"
+		if !result.OK {
+			outcome = "failed"
+		}
+		parts[i] = result.Host + "=" + outcome
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// orDash returns "-" for an empty value, to keep table columns aligned.
+func orDash(value string) string {
+
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+// yesNo formats a boolean for the table.
+func yesNo(b bool) string {
+
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
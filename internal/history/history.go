@@ -0,0 +1,194 @@
+package history
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Drakx/ZonoCaller/internal/state"
+)
+
+// Filter selects history entries. Zero values match everything.
+type Filter struct {
+	Since       time.Time
+	Until       time.Time
+	ChangesOnly bool
+	Host        string
+}
+
+// Summary holds statistics about IP changes.
+type Summary struct {
+	Runs    int `json:"runs"`
+	Changes int `json:"changes"`
+	Failed  int `json:"failed"`
+	// ChangesPerMonth counts changes by month, e.g. "2025-08", oldest first
+	ChangesPerMonth []MonthCount `json:"changes_per_month"`
+	// AverageIPLifetime is the mean time an IP was in use before it changed
+	AverageIPLifetime Duration `json:"average_ip_lifetime"`
+}
+
+// MonthCount is the number of IP changes in a month.
+type MonthCount struct {
+	Month   string `json:"month"`
+	Changes int    `json:"changes"`
+}
+
+// Duration is a time.Duration that encodes as a string such as "72h0m0s".
+type Duration time.Duration
+
+// MarshalText encodes d as a Go duration string.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText decodes a Go duration string.
+func (d *Duration) UnmarshalText(text []byte) error {
+
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// String formats d like time.Duration.
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// ParseTime parses a filter bound given as RFC 3339 or as a date
+// (YYYY-MM-DD), which is the start of that day in loc. It reports whether
+// value was a date.
+func ParseTime(value string, loc *time.Location) (time.Time, bool, error) {
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+
+	t, err := time.ParseInLocation(time.DateOnly, value, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid time %q, expected RFC 3339 or YYYY-MM-DD", value)
+	}
+
+	return t, true, nil
+}
+
+// Normalize fills in the previous IP and change flag of entries written
+// before the schema recorded them, by comparing each IP with the previous IP
+// of the same family. Entries must be oldest first.
+func Normalize(entries []state.Entry) []state.Entry {
+
+	normalized := slices.Clone(entries)
+	last := map[state.Family]string{}
+	for i, entry := range normalized {
+		family := state.FamilyOf(entry.IP)
+		if family == "" {
+			continue
+		}
+
+		if entry.SchemaVersion() == 1 {
+			normalized[i].PreviousIP = last[family]
+			normalized[i].Changed = entry.IP != last[family]
+		}
+		last[family] = entry.IP
+	}
+
+	return normalized
+}
+
+// Query returns the normalized entries that match filter, oldest first.
+func Query(entries []state.Entry, filter Filter) []state.Entry {
+
+	var matched []state.Entry
+	for _, entry := range Normalize(entries) {
+		if filter.matches(entry) {
+			matched = append(matched, entry)
+		}
+	}
+
+	return matched
+}
+
+// Summarize computes statistics over entries, which must be oldest first and
+// normalized.
+func Summarize(entries []state.Entry) Summary {
+
+	summary := Summary{ChangesPerMonth: []MonthCount{}}
+
+	var lifetimes []time.Duration
+	lastChange := map[state.Family]time.Time{}
+	for _, entry := range entries {
+		summary.Runs++
+		if entry.Error != "" {
+			summary.Failed++
+		}
+
+		if !entry.Changed {
+			continue
+		}
+		summary.Changes++
+
+		at, err := time.Parse(time.RFC3339, entry.Timestamp)
+		if err != nil {
+			continue
+		}
+
+		month := at.Format("2006-01")
+		if n := len(summary.ChangesPerMonth); n > 0 && summary.ChangesPerMonth[n-1].Month == month {
+			summary.ChangesPerMonth[n-1].Changes++
+		} else {
+			summary.ChangesPerMonth = append(summary.ChangesPerMonth, MonthCount{Month: month, Changes: 1})
+		}
+
+		// The previous IP lived from its own change until this one
+		family := state.FamilyOf(entry.IP)
+		if previous, ok := lastChange[family]; ok {
+			lifetimes = append(lifetimes, at.Sub(previous))
+		}
+		lastChange[family] = at
+	}
+
+	if len(lifetimes) > 0 {
+		var total time.Duration
+		for _, lifetime := range lifetimes {
+			total += lifetime
+		}
+		summary.AverageIPLifetime = Duration(total / time.Duration(len(lifetimes)))
+	}
+
+	return summary
+}
+
+// matches reports whether entry passes the filter.
+func (f Filter) matches(entry state.Entry) bool {
+
+	if f.ChangesOnly && !entry.Changed {
+		return false
+	}
+
+	if !f.Since.IsZero() || !f.Until.IsZero() {
+		at, err := time.Parse(time.RFC3339, entry.Timestamp)
+		if err != nil {
+			return false
+		}
+
+		if !f.Since.IsZero() && at.Before(f.Since) {
+			return false
+		}
+
+		if !f.Until.IsZero() && !at.Before(f.Until) {
+			return false
+		}
+	}
+
+	if f.Host != "" {
+		return slices.ContainsFunc(entry.Hosts, func(result state.HostResult) bool {
+			return strings.EqualFold(result.Host, f.Host)
+		})
+	}
+
+	return true
+}
@@ -0,0 +1,211 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Drakx/ZonoCaller/internal/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testEntries returns a history with a v1 entry, an unchanged run, two
+// changes and a failure, oldest first.
+func testEntries() []state.Entry {
+	return []state.Entry{
+		{IP: "192.168.1.1", Timestamp: "2025-07-01T12:00:00Z"},
+		{Version: state.EntryVersion, IP: "192.168.1.1", Timestamp: "2025-07-02T12:00:00Z", PreviousIP: "192.168.1.1"},
+		{Version: state.EntryVersion, IP: "192.168.1.2", Timestamp: "2025-07-04T12:00:00Z", PreviousIP: "192.168.1.1", Changed: true,
+			Hosts: []state.HostResult{{Host: "a.example.com", Value: "192.168.1.2", OK: true}}},
+		{Version: state.EntryVersion, Timestamp: "2025-08-01T12:00:00Z", Error: "no IP"},
+		{Version: state.EntryVersion, IP: "192.168.1.3", Timestamp: "2025-08-10T12:00:00Z", PreviousIP: "192.168.1.2", Changed: true,
+			Hosts: []state.HostResult{{Host: "b.example.com", Value: "192.168.1.3", OK: true}}},
+	}
+}
+
+func TestNormalize(t *testing.T) {
+
+	entries := Normalize([]state.Entry{
+		{IP: "192.168.1.1", Timestamp: "2025-07-01T12:00:00Z"},
+		{IP: "2001:db8::1", Timestamp: "2025-07-01T12:00:00Z"},
+		{IP: "192.168.1.1", Timestamp: "2025-07-02T12:00:00Z"},
+		{IP: "192.168.1.2", Timestamp: "2025-07-03T12:00:00Z"},
+	})
+
+	// The first entry of each family is a change from nothing
+	assert.True(t, entries[0].Changed)
+	assert.True(t, entries[1].Changed)
+	assert.False(t, entries[2].Changed)
+	assert.Equal(t, "192.168.1.1", entries[2].PreviousIP)
+	assert.True(t, entries[3].Changed)
+	assert.Equal(t, "192.168.1.1", entries[3].PreviousIP)
+}
+
+func TestQuery(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"all", Filter{}, []string{"2025-07-01T12:00:00Z", "2025-07-02T12:00:00Z", "2025-07-04T12:00:00Z", "2025-08-01T12:00:00Z", "2025-08-10T12:00:00Z"}},
+		{"changes only", Filter{ChangesOnly: true}, []string{"2025-07-01T12:00:00Z", "2025-07-04T12:00:00Z", "2025-08-10T12:00:00Z"}},
+		{"since", Filter{Since: time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)}, []string{"2025-08-01T12:00:00Z", "2025-08-10T12:00:00Z"}},
+		{"until is exclusive", Filter{Until: time.Date(2025, 7, 4, 12, 0, 0, 0, time.UTC)}, []string{"2025-07-01T12:00:00Z", "2025-07-02T12:00:00Z"}},
+		{"host", Filter{Host: "B.example.com"}, []string{"2025-08-10T12:00:00Z"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, entry := range Query(testEntries(), tt.filter) {
+				got = append(got, entry.Timestamp)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+
+	summary := Summarize(Query(testEntries(), Filter{}))
+
+	assert.Equal(t, 5, summary.Runs)
+	assert.Equal(t, 3, summary.Changes)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, []MonthCount{{Month: "2025-07", Changes: 2}, {Month: "2025-08", Changes: 1}}, summary.ChangesPerMonth)
+
+	// 192.168.1.1 lived 3 days and 192.168.1.2 lived 37 days
+	assert.Equal(t, Duration(20*24*time.Hour), summary.AverageIPLifetime)
+}
+
+func TestParseTime(t *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+
+	// Dates start in the given location
+	at, date, err := ParseTime("2025-08-01", london)
+	require.NoError(t, err)
+	assert.True(t, date)
+	assert.True(t, at.Equal(time.Date(2025, 7, 31, 23, 0, 0, 0, time.UTC)))
+
+	at, date, err = ParseTime("2025-08-01T10:00:00+02:00", london)
+	require.NoError(t, err)
+	assert.False(t, date)
+	assert.True(t, at.Equal(time.Date(2025, 8, 1, 8, 0, 0, 0, time.UTC)))
+
+	_, _, err = ParseTime("yesterday", london)
+	assert.Error(t, err)
+}
+
+func TestParseFilter_Dates(t *testing.T) {
+
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+
+	filter, err := ParseFilter(url.Values{"since": {"2025-08-01"}, "until": {"2025-08-31"}}, london)
+	require.NoError(t, err)
+
+	tests := []struct {
+		timestamp string
+		expected  bool
+	}{
+		// Entries are written in the service's local time
+		{"2025-08-01T00:30:00+01:00", true},
+		{"2025-07-31T23:30:00+01:00", false},
+		// The whole of the until date is included
+		{"2025-08-31T23:59:59+01:00", true},
+		{"2025-09-01T00:00:00+01:00", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.timestamp, func(t *testing.T) {
+			assert.Equal(t, tt.expected, filter.matches(state.Entry{Timestamp: tt.timestamp}))
+		})
+	}
+
+	// An RFC 3339 until stays exclusive
+	filter, err = ParseFilter(url.Values{"until": {"2025-08-31T12:00:00Z"}}, london)
+	require.NoError(t, err)
+	assert.False(t, filter.matches(state.Entry{Timestamp: "2025-08-31T12:00:00Z"}))
+}
+
+func TestWrite(t *testing.T) {
+
+	entries := Query(testEntries(), Filter{ChangesOnly: true})
+	summary := Summarize(entries)
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FormatTable, entries, summary))
+	assert.Contains(t, buf.String(), "TIME")
+	assert.Contains(t, buf.String(), "a.example.com")
+	assert.Contains(t, buf.String(), "Runs: 3, changes: 3, failed: 0")
+	assert.Contains(t, buf.String(), "2025-08: 1")
+
+	buf.Reset()
+	require.NoError(t, Write(&buf, FormatCSV, entries, summary))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "timestamp,ip,previous_ip,changed"))
+
+	buf.Reset()
+	require.NoError(t, Write(&buf, FormatJSON, entries, summary))
+	var report struct {
+		Entries []state.Entry `json:"entries"`
+		Summary struct {
+			Changes           int    `json:"changes"`
+			AverageIPLifetime string `json:"average_ip_lifetime"`
+		} `json:"summary"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	assert.Len(t, report.Entries, 3)
+	assert.Equal(t, 3, report.Summary.Changes)
+	assert.Equal(t, "480h0m0s", report.Summary.AverageIPLifetime)
+
+	assert.Error(t, Write(&buf, "xml", entries, summary))
+}
+
+func TestHandler(t *testing.T) {
+
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "ip_log.txt"))
+	for _, entry := range testEntries() {
+		require.NoError(t, store.Record(entry))
+	}
+	handler := Handler(store, time.UTC)
+
+	// JSON by default
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/history?changes=true&since=2025-07-03", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var report Report
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Len(t, report.Entries, 2)
+
+	// CSV on request
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/history?format=csv", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	// Bad input
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/history?since=soon", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/history?format=xml", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/history", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,97 @@
+package history
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Drakx/ZonoCaller/internal/state"
+)
+
+// Handler serves the history in store. Query parameters mirror the history
+// command's flags: since, until, changes, host and format (default json).
+// Dates are days in loc.
+func Handler(store state.Store, loc *time.Location) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		filter, err := ParseFilter(r.URL.Query(), loc)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = FormatJSON
+		}
+
+		contentType, ok := contentTypes[format]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown format %q, expected table, json or csv", format), http.StatusBadRequest)
+			return
+		}
+
+		entries, err := store.History()
+		if err != nil {
+			http.Error(w, "failed to read history", http.StatusInternalServerError)
+			return
+		}
+
+		matched := Query(entries, filter)
+
+		w.Header().Set("Content-Type", contentType)
+		Write(w, format, matched, Summarize(matched))
+	})
+}
+
+// contentTypes maps each output format to its HTTP content type.
+var contentTypes = map[string]string{
+	FormatTable: "text/plain; charset=utf-8",
+	FormatJSON:  "application/json",
+	FormatCSV:   "text/csv; charset=utf-8",
+}
+
+// ParseFilter reads a filter from the since, until, changes and host values.
+// Dates are days in loc, and a date given as until includes that whole day.
+func ParseFilter(values url.Values, loc *time.Location) (Filter, error) {
+
+	var filter Filter
+	var err error
+
+	if since := values.Get("since"); since != "" {
+		if filter.Since, _, err = ParseTime(since, loc); err != nil {
+			return Filter{}, fmt.Errorf("since: %w", err)
+		}
+	}
+
+	if until := values.Get("until"); until != "" {
+		var date bool
+		if filter.Until, date, err = ParseTime(until, loc); err != nil {
+			return Filter{}, fmt.Errorf("until: %w", err)
+		}
+
+		// Until is exclusive, so end a date at the start of the next day
+		if date {
+			filter.Until = filter.Until.AddDate(0, 0, 1)
+		}
+	}
+
+	if changes := values.Get("changes"); changes != "" {
+		if filter.ChangesOnly, err = strconv.ParseBool(changes); err != nil {
+			return Filter{}, fmt.Errorf("changes must be true or false, got %q", changes)
+		}
+	}
+
+	filter.Host = values.Get("host")
+
+	return filter, nil
+}
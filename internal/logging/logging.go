@@ -0,0 +1,154 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces every masked value.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys and URL query parameters whose values are
+// always masked. Keys are matched case-insensitively, with "-" treated as "_".
+var sensitiveKeys = []string{
+	"api_key",
+	"apikey",
+	"access_token",
+	"token",
+	"password",
+	"passwd",
+	"passphrase",
+	"secret",
+	"encryption_key",
+	"authorization",
+}
+
+// sensitivePattern matches sensitive query parameters and bearer tokens
+// inside free text such as URLs and error messages.
+var sensitivePattern = regexp.MustCompile(
+	`(?i)((?:` + strings.Join(sensitiveKeys, "|") + `)=)[^&;\s"'<>]*` +
+		`|(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`,
+)
+
+// New returns a JSON logger writing to w that masks secrets in every record.
+func New(w io.Writer) *slog.Logger {
+
+	return slog.New(NewHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:     slog.LevelInfo,
+		AddSource: true,
+	})))
+}
+
+// Handler is a slog.Handler that masks sensitive attributes and query
+// parameters before passing records to the next handler.
+type Handler struct {
+	next slog.Handler
+}
+
+// NewHandler wraps next so that every record it receives is redacted.
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{next: next}
+}
+
+// Enabled reports whether the next handler handles records at level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle redacts the message and attributes of r and passes it on.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+
+	redacted := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(a))
+		return true
+	})
+
+	return h.next.Handle(ctx, redacted)
+}
+
+// WithAttrs returns a handler whose attributes are redacted up front.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+
+	return &Handler{next: h.next.WithAttrs(redacted)}
+}
+
+// WithGroup returns a handler that nests attributes under name.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name)}
+}
+
+// Redact masks sensitive query parameters and bearer tokens in s.
+func Redact(s string) string {
+
+	return sensitivePattern.ReplaceAllStringFunc(s, func(match string) string {
+		groups := sensitivePattern.FindStringSubmatch(match)
+		if groups[1] != "" {
+			return groups[1] + Redacted
+		}
+
+		return groups[2] + Redacted
+	})
+}
+
+// IsSensitive reports whether an attribute or parameter named key holds a secret.
+func IsSensitive(key string) bool {
+
+	name := strings.ReplaceAll(strings.ToLower(key), "-", "_")
+	if name == "key" {
+		return true
+	}
+
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(name, sensitive) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactAttr masks a, descending into groups. Errors and other values that
+// format as text are redacted as strings.
+func redactAttr(a slog.Attr) slog.Attr {
+
+	a.Value = a.Value.Resolve()
+
+	if IsSensitive(a.Key) && a.Value.Kind() != slog.KindGroup {
+		if a.Value.Kind() == slog.KindString && a.Value.String() == "" {
+			return a
+		}
+
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, attr := range group {
+			redacted[i] = redactAttr(attr)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, Redact(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, Redact(v.String()))
+		}
+	}
+
+	return a
+}
@@ -0,0 +1,104 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Query parameter",
+			input:    "https://zonomi.com/app/dns/dyndns.jsp?api_key=secret-key&name=a.example.com",
+			expected: "https://zonomi.com/app/dns/dyndns.jsp?api_key=[REDACTED]&name=a.example.com",
+		},
+		{
+			name:     "Last query parameter",
+			input:    "https://example.com/?name=a&token=abc123",
+			expected: "https://example.com/?name=a&token=[REDACTED]",
+		},
+		{
+			name:     "Case insensitive",
+			input:    "https://example.com/?API_KEY=abc123",
+			expected: "https://example.com/?API_KEY=[REDACTED]",
+		},
+		{
+			name:     "Quoted URL in error",
+			input:    `Get "https://example.com/?api_key=abc123": dial tcp: connection refused`,
+			expected: `Get "https://example.com/?api_key=[REDACTED]": dial tcp: connection refused`,
+		},
+		{
+			name:     "Bearer token",
+			input:    "Authorization: Bearer abc.def-123",
+			expected: "Authorization: Bearer [REDACTED]",
+		},
+		{
+			name:     "Nothing sensitive",
+			input:    "https://api.ipify.org?format=json",
+			expected: "https://api.ipify.org?format=json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Redact(tt.input))
+		})
+	}
+}
+
+func TestIsSensitive(t *testing.T) {
+
+	for _, key := range []string{"api_key", "API-KEY", "zonomi_api_key", "key", "password", "webhook_token", "encryption_key"} {
+		assert.True(t, IsSensitive(key), key)
+	}
+
+	for _, key := range []string{"host", "url", "ip", "error", "keys_count"} {
+		assert.False(t, IsSensitive(key), key)
+	}
+}
+
+func TestHandler_NoSecretReachesOutput(t *testing.T) {
+
+	const secret = "super-secret-value"
+
+	var buf bytes.Buffer
+	logger := New(&buf)
+
+	urlErr := &url.Error{Op: "Get", URL: "https://example.com/?api_key=" + secret, Err: errors.New("connection refused")}
+	u, _ := url.Parse("https://example.com/?token=" + secret)
+
+	logger.Info("Calling API", "url", "https://example.com/?api_key="+secret)
+	logger.Info("Message with https://example.com/?api_key=" + secret)
+	logger.Error("Request failed", "error", urlErr)
+	logger.Error("Wrapped", "error", errors.Join(errors.New("failed"), urlErr))
+	logger.Info("Stringer", "url", u)
+	logger.Info("Sensitive key", "api_key", secret, "password", secret)
+	logger.Info("Group", slog.Group("zonomi", "token", secret, "url", "https://example.com/?api_key="+secret))
+	logger.With("secret", secret).WithGroup("request").Info("With attrs", "authorization", "Bearer "+secret)
+
+	assert.NotContains(t, buf.String(), secret)
+	assert.Contains(t, buf.String(), Redacted)
+	assert.Contains(t, buf.String(), "connection refused")
+}
+
+func TestHandler_KeepsOtherValues(t *testing.T) {
+
+	var buf bytes.Buffer
+	logger := New(&buf)
+
+	logger.Info("Zonomi DNS updated", "ip", "192.168.1.1", "hosts", []string{"a.example.com"}, "api_key", "")
+
+	assert.Contains(t, buf.String(), `"ip":"192.168.1.1"`)
+	assert.Contains(t, buf.String(), `"hosts":["a.example.com"]`)
+	assert.Contains(t, buf.String(), `"api_key":""`)
+	assert.Contains(t, buf.String(), `"source"`)
+}
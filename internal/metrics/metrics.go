@@ -0,0 +1,155 @@
+// Package metrics exposes Prometheus metrics for runs, DNS updates and the
+// fetcher's HTTP requests.
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Drakx/ZonoCaller/internal/fetcher"
+	"github.com/Drakx/ZonoCaller/internal/state"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "zonocaller"
+
+// Outcomes of runs and DNS updates.
+const (
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+)
+
+// Metrics collects the service's metrics in its own registry.
+type Metrics struct {
+	registry        *prometheus.Registry
+	runs            *prometheus.CounterVec
+	ipChanges       prometheus.Counter
+	ipInfo          *prometheus.GaugeVec
+	dnsUpdates      *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	retries         *prometheus.CounterVec
+
+	mu          sync.Mutex
+	lastSuccess time.Time
+}
+
+// New creates and registers the metrics, along with the Go runtime and
+// process collectors.
+func New() *Metrics {
+
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "runs_total",
+			Help:      "Runs by outcome. Skipped runs are not counted.",
+		}, []string{"outcome"}),
+		ipChanges: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ip_changes_total",
+			Help:      "Changes of the public IP since a previously recorded IP.",
+		}),
+		ipInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "ip_info",
+			Help:      "The current public IP of each address family, as a label; always 1.",
+		}, []string{"ip", "family"}),
+		dnsUpdates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "dns_updates_total",
+			Help:      "DNS host updates by host and outcome.",
+		}, []string{"host", "outcome"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP request attempts to the IP source and the Zonomi API.",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"api"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_retries_total",
+			Help:      "Retries of failed HTTP requests to the IP source and the Zonomi API.",
+		}, []string{"api"}),
+		lastSuccess: time.Now(),
+	}
+
+	// Until the first success, count from startup
+	sinceSuccess := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "seconds_since_last_success",
+		Help:      "Seconds since the last successful run, or since startup before the first.",
+	}, func() float64 {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return time.Since(m.lastSuccess).Seconds()
+	})
+
+	m.registry.MustRegister(
+		m.runs, m.ipChanges, m.ipInfo, m.dnsUpdates, m.requestDuration, m.retries, sinceSuccess,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	// Export the fixed label values before anything has happened
+	for _, outcome := range []string{outcomeSuccess, outcomeFailure} {
+		m.runs.WithLabelValues(outcome)
+	}
+	for _, api := range []string{fetcher.APIIPSource, fetcher.APIZonomi} {
+		m.requestDuration.WithLabelValues(api)
+		m.retries.WithLabelValues(api)
+	}
+
+	return m
+}
+
+// RecordRun records the outcome of a run. It is meant to be passed to
+// scheduler.WithRunObserver.
+func (m *Metrics) RecordRun(result state.Entry, err error) {
+
+	if err != nil {
+		m.runs.WithLabelValues(outcomeFailure).Inc()
+	} else {
+		m.runs.WithLabelValues(outcomeSuccess).Inc()
+
+		m.mu.Lock()
+		m.lastSuccess = time.Now()
+		m.mu.Unlock()
+	}
+
+	// The first run has no previous IP to change from
+	if result.Changed && result.PreviousIP != "" {
+		m.ipChanges.Inc()
+	}
+
+	if result.IP != "" {
+		family := string(state.FamilyOf(result.IP))
+		m.ipInfo.DeletePartialMatch(prometheus.Labels{"family": family})
+		m.ipInfo.WithLabelValues(result.IP, family).Set(1)
+	}
+
+	for _, host := range result.Hosts {
+		outcome := outcomeSuccess
+		if !host.OK {
+			outcome = outcomeFailure
+		}
+		m.dnsUpdates.WithLabelValues(host.Host, outcome).Inc()
+	}
+}
+
+// ObserveRequest implements fetcher.Metrics.
+func (m *Metrics) ObserveRequest(api string, duration time.Duration) {
+	m.requestDuration.WithLabelValues(api).Observe(duration.Seconds())
+}
+
+// Retry implements fetcher.Metrics.
+func (m *Metrics) Retry(api string) {
+	m.retries.WithLabelValues(api).Inc()
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Drakx/ZonoCaller/internal/fetcher"
+	"github.com/Drakx/ZonoCaller/internal/state"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_RecordRun(t *testing.T) {
+	m := New()
+
+	// The first run sets the IP without counting a change
+	m.RecordRun(state.Entry{IP: "192.168.1.1", Changed: true, Hosts: []state.HostResult{
+		{Host: "a.example.com", OK: true},
+		{Host: "b.example.com", Error: "HTTP 500"},
+	}}, errors.New("errors updating hosts"))
+	m.RecordRun(state.Entry{IP: "192.168.1.2", PreviousIP: "192.168.1.1", Changed: true, Hosts: []state.HostResult{
+		{Host: "a.example.com", OK: true},
+		{Host: "b.example.com", OK: true},
+	}}, nil)
+	m.RecordRun(state.Entry{IP: "2001:db8::1", PreviousIP: "2001:db8::1"}, nil)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.runs.WithLabelValues(outcomeSuccess)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.runs.WithLabelValues(outcomeFailure)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.ipChanges))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.dnsUpdates.WithLabelValues("a.example.com", outcomeSuccess)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.dnsUpdates.WithLabelValues("b.example.com", outcomeFailure)))
+
+	// Only the current IP of each family is exported
+	err := testutil.CollectAndCompare(m.ipInfo, strings.NewReader(`
+# HELP zonocaller_ip_info The current public IP of each address family, as a label; always 1.
+# TYPE zonocaller_ip_info gauge
+zonocaller_ip_info{family="ipv4",ip="192.168.1.2"} 1
+zonocaller_ip_info{family="ipv6",ip="2001:db8::1"} 1
+`))
+	assert.NoError(t, err)
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	m := New()
+	m.ObserveRequest(fetcher.APIIPSource, 120*time.Millisecond)
+	m.Retry(fetcher.APIZonomi)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	assert.Contains(t, body, `zonocaller_runs_total{outcome="failure"} 0`)
+	assert.Contains(t, body, `zonocaller_http_request_duration_seconds_count{api="ip_source"} 1`)
+	assert.Contains(t, body, `zonocaller_http_request_duration_seconds_bucket{api="ip_source",le="0.25"} 1`)
+	assert.Contains(t, body, `zonocaller_http_retries_total{api="zonomi"} 1`)
+	assert.Contains(t, body, "zonocaller_seconds_since_last_success ")
+	assert.Contains(t, body, "go_goroutines ")
+}
@@ -0,0 +1,54 @@
+// Package netwatch reports changes to the host's network addresses and
+// default routes, which usually mean the public IP has changed.
+package netwatch
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrUnsupported is returned by Watch where network change events are not
+// available.
+var ErrUnsupported = errors.New("network change events are not supported on this platform")
+
+// Watch calls onChange once the network has been quiet for debounce after
+// one or more address or default route changes, with the last change seen.
+// It returns when ctx is cancelled, or an error if events cannot be received.
+func Watch(ctx context.Context, debounce time.Duration, onChange func(event string)) error {
+
+	events, err := subscribe(ctx)
+	if err != nil {
+		return err
+	}
+
+	return debounceEvents(ctx, events, debounce, onChange)
+}
+
+// debounceEvents calls onChange after no event has arrived for wait. It
+// returns when ctx is cancelled, or an error if events is closed first.
+func debounceEvents(ctx context.Context, events <-chan string, wait time.Duration, onChange func(event string)) error {
+
+	timer := time.NewTimer(wait)
+	timer.Stop()
+	defer timer.Stop()
+
+	var last string
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return errors.New("network event subscription closed")
+			}
+			last = event
+			timer.Reset(wait)
+		case <-timer.C:
+			onChange(last)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+//go:build linux
+
+package netwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// Netlink multicast groups for address and route changes, from
+// linux/rtnetlink.h; the syscall package does not define them.
+const (
+	rtmgrpIPv4IfAddr = 0x10
+	rtmgrpIPv4Route  = 0x40
+	rtmgrpIPv6IfAddr = 0x100
+	rtmgrpIPv6Route  = 0x400
+
+	groups = rtmgrpIPv4IfAddr | rtmgrpIPv4Route | rtmgrpIPv6IfAddr | rtmgrpIPv6Route
+)
+
+// subscribe opens a netlink socket and sends a description of every
+// relevant change until ctx is cancelled or reading fails, then closes the
+// channel. If the socket buffer overflows, "changes lost" is sent instead
+// of the dropped changes.
+func subscribe(ctx context.Context) (<-chan string, error) {
+
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open netlink socket: %w", err)
+	}
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to subscribe to netlink events: %w", err)
+	}
+
+	// Wake up regularly to notice cancellation
+	timeout := syscall.Timeval{Sec: 1}
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to set netlink socket timeout: %w", err)
+	}
+
+	events := make(chan string, 16)
+	go func() {
+		defer close(events)
+		defer syscall.Close(fd)
+
+		buf := make([]byte, 64*1024)
+		for ctx.Err() == nil {
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			if err != nil {
+				event, ok := recvError(err)
+				if !ok {
+					return
+				}
+				if event != "" {
+					send(events, event)
+				}
+				continue
+			}
+
+			messages, err := syscall.ParseNetlinkMessage(buf[:n])
+			if err != nil {
+				continue
+			}
+
+			for _, message := range messages {
+				if event := describe(message); event != "" {
+					send(events, event)
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// send passes event on without blocking. Events are debounced, so dropping
+// some while the consumer is busy loses nothing.
+func send(events chan<- string, event string) {
+
+	select {
+	case events <- event:
+	default:
+	}
+}
+
+// recvError reports whether reading can continue after err, and the event
+// to send if so. ENOBUFS means the socket buffer overflowed and changes were
+// lost, so it counts as a change itself.
+func recvError(err error) (string, bool) {
+
+	switch {
+	case errors.Is(err, syscall.ENOBUFS):
+		return "changes lost", true
+	case errors.Is(err, syscall.EAGAIN), errors.Is(err, syscall.EINTR):
+		return "", true
+	default:
+		return "", false
+	}
+}
+
+// describe returns a description of message if it is a change that may
+// affect the public IP, or "". Only global addresses and default routes count.
+func describe(message syscall.NetlinkMessage) string {
+
+	switch message.Header.Type {
+	case syscall.RTM_NEWADDR, syscall.RTM_DELADDR:
+		if len(message.Data) < syscall.SizeofIfAddrmsg {
+			return ""
+		}
+		addr := (*syscall.IfAddrmsg)(unsafe.Pointer(&message.Data[0]))
+		if addr.Scope != syscall.RT_SCOPE_UNIVERSE {
+			return ""
+		}
+		if message.Header.Type == syscall.RTM_NEWADDR {
+			return "address added"
+		}
+		return "address removed"
+
+	case syscall.RTM_NEWROUTE, syscall.RTM_DELROUTE:
+		if len(message.Data) < syscall.SizeofRtMsg {
+			return ""
+		}
+		route := (*syscall.RtMsg)(unsafe.Pointer(&message.Data[0]))
+		if route.Dst_len != 0 || route.Table != syscall.RT_TABLE_MAIN {
+			return ""
+		}
+		if message.Header.Type == syscall.RTM_NEWROUTE {
+			return "default route added"
+		}
+		return "default route removed"
+	}
+
+	return ""
+}
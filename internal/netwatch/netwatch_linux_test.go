@@ -0,0 +1,94 @@
+//go:build linux
+
+package netwatch
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribe(t *testing.T) {
+
+	addr := func(msgType uint16, scope uint8) syscall.NetlinkMessage {
+		data := make([]byte, syscall.SizeofIfAddrmsg)
+		(*syscall.IfAddrmsg)(unsafe.Pointer(&data[0])).Scope = scope
+		return syscall.NetlinkMessage{Header: syscall.NlMsghdr{Type: msgType}, Data: data}
+	}
+	route := func(msgType uint16, dstLen uint8) syscall.NetlinkMessage {
+		data := make([]byte, syscall.SizeofRtMsg)
+		msg := (*syscall.RtMsg)(unsafe.Pointer(&data[0]))
+		msg.Dst_len = dstLen
+		msg.Table = syscall.RT_TABLE_MAIN
+		return syscall.NetlinkMessage{Header: syscall.NlMsghdr{Type: msgType}, Data: data}
+	}
+
+	tests := []struct {
+		name     string
+		message  syscall.NetlinkMessage
+		expected string
+	}{
+		{"global address added", addr(syscall.RTM_NEWADDR, syscall.RT_SCOPE_UNIVERSE), "address added"},
+		{"global address removed", addr(syscall.RTM_DELADDR, syscall.RT_SCOPE_UNIVERSE), "address removed"},
+		{"link-local address", addr(syscall.RTM_NEWADDR, syscall.RT_SCOPE_LINK), ""},
+		{"default route added", route(syscall.RTM_NEWROUTE, 0), "default route added"},
+		{"default route removed", route(syscall.RTM_DELROUTE, 0), "default route removed"},
+		{"subnet route", route(syscall.RTM_NEWROUTE, 24), ""},
+		{"truncated", syscall.NetlinkMessage{Header: syscall.NlMsghdr{Type: syscall.RTM_NEWADDR}}, ""},
+		{"link change", syscall.NetlinkMessage{Header: syscall.NlMsghdr{Type: syscall.RTM_NEWLINK}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, describe(tt.message))
+		})
+	}
+}
+
+func TestRecvError(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		err   error
+		event string
+		ok    bool
+	}{
+		{"buffer overflow", syscall.ENOBUFS, "changes lost", true},
+		{"timeout", syscall.EAGAIN, "", true},
+		{"interrupted", syscall.EINTR, "", true},
+		{"closed", syscall.EBADF, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, ok := recvError(tt.err)
+			assert.Equal(t, tt.event, event)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := subscribe(ctx)
+	if err != nil {
+		t.Skipf("netlink not available: %v", err)
+	}
+
+	// The channel is closed soon after cancellation
+	cancel()
+	require.Eventually(t, func() bool {
+		select {
+		case _, ok := <-events:
+			return !ok
+		default:
+			return false
+		}
+	}, 3*time.Second, 10*time.Millisecond)
+}
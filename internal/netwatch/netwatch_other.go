@@ -0,0 +1,10 @@
+//go:build !linux
+
+package netwatch
+
+import "context"
+
+// subscribe is not supported without netlink.
+func subscribe(ctx context.Context) (<-chan string, error) {
+	return nil, ErrUnsupported
+}
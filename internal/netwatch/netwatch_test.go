@@ -0,0 +1,51 @@
+package netwatch
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebounceEvents(t *testing.T) {
+
+	events := make(chan string)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+	var last atomic.Value
+	done := make(chan error)
+	go func() {
+		done <- debounceEvents(ctx, events, 50*time.Millisecond, func(event string) {
+			calls.Add(1)
+			last.Store(event)
+		})
+	}()
+
+	// A burst of events triggers a single call with the last event
+	events <- "address removed"
+	events <- "default route removed"
+	events <- "address added"
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "address added", last.Load())
+
+	// Later events trigger another call
+	events <- "default route added"
+	require.Eventually(t, func() bool { return calls.Load() == 2 }, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestDebounceEvents_Closed(t *testing.T) {
+
+	events := make(chan string)
+	close(events)
+
+	err := debounceEvents(context.Background(), events, time.Second, func(string) {})
+	assert.Error(t, err)
+}
@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/Drakx/ZonoCaller/internal/state"
+)
+
+// TriggerHandler serves POST requests that run the fetch job now and respond
+// with the run's record as JSON. The force query parameter updates DNS even
+// if the IP is unchanged.
+func TriggerHandler(s *Scheduler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		var force bool
+		if value := r.URL.Query().Get("force"); value != "" {
+			var err error
+			if force, err = strconv.ParseBool(value); err != nil {
+				writeError(w, http.StatusBadRequest, "force must be true or false")
+				return
+			}
+		}
+
+		// The run is bounded by RUN_TIMEOUT, which may exceed
+		// HTTP_WRITE_TIMEOUT
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		result, err := s.Trigger(force, "trigger")
+		switch {
+		case errors.Is(err, ErrRunInProgress), errors.Is(err, ErrPaused), errors.Is(err, ErrMaintenance):
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		case errors.Is(err, ErrNotRunning):
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+
+		// A failed run is still reported, with its error
+		status := http.StatusOK
+		if err != nil {
+			status = http.StatusBadGateway
+			if result.Error == "" {
+				result.Error = err.Error()
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+// PauseHandler serves POST requests that pause runs and respond with the
+// paused state as JSON. The reason query parameter is recorded with the
+// pause, and the for parameter (e.g. "2h") resumes runs after that long.
+func PauseHandler(s *Scheduler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		var duration time.Duration
+		if value := r.URL.Query().Get("for"); value != "" {
+			var err error
+			if duration, err = time.ParseDuration(value); err != nil || duration <= 0 {
+				writeError(w, http.StatusBadRequest, "for must be a positive duration")
+				return
+			}
+		}
+
+		pause, err := s.Pause(r.URL.Query().Get("reason"), duration)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pause)
+	})
+}
+
+// ResumeHandler serves POST requests that end a pause.
+func ResumeHandler(s *Scheduler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		if err := s.Resume(); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state.Pause{})
+	})
+}
+
+// RequireToken serves requests with next only if they carry the admin
+// token as "Authorization: Bearer <token>". Without a configured token the
+// endpoints are disabled, since anyone reaching the port could use them.
+func RequireToken(token config.Secret, next http.Handler) http.Handler {
+
+	want := sha256.Sum256([]byte(token.Reveal()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if token == "" {
+			writeError(w, http.StatusForbidden, "admin endpoints are disabled; set ADMIN_TOKEN to enable them")
+			return
+		}
+
+		// Compare digests so neither the contents nor the length leak
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		digest := sha256.Sum256([]byte(got))
+		if !ok || subtle.ConstantTimeCompare(digest[:], want[:]) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "invalid or missing admin token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeError responds with a JSON error message.
+func writeError(w http.ResponseWriter, status int, message string) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,265 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/Drakx/ZonoCaller/internal/fetcher"
+	"github.com/Drakx/ZonoCaller/internal/state"
+	"github.com/go-co-op/gocron/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// triggerFetcher records whether runs were forced and reports each run.
+type triggerFetcher struct {
+	forced bool
+	err    error
+}
+
+func (f *triggerFetcher) FetchIP(ctx context.Context) error {
+	f.forced = fetcher.Forced(ctx)
+	return f.err
+}
+
+func (f *triggerFetcher) LastResult() state.Entry {
+	entry := state.Entry{IP: "192.168.1.1", Forced: f.forced}
+	if f.err != nil {
+		entry.Error = f.err.Error()
+	}
+	return entry
+}
+
+// failingFetcher fails every run and sends whether it was forced.
+type failingFetcher struct {
+	forced chan bool
+}
+
+func (f *failingFetcher) FetchIP(ctx context.Context) error {
+	f.forced <- fetcher.Forced(ctx)
+	return errors.New("HTTP request failed")
+}
+
+func TestTrigger_ForcedFollowUp(t *testing.T) {
+	cfg := config.Config{
+		Timezone:            "UTC",
+		Schedule:            "0 0 1 1 *",
+		OverlapPolicy:       config.OverlapSkip,
+		AdaptiveSchedule:    true,
+		AdaptiveMinInterval: 5 * time.Minute,
+		AdaptiveMaxInterval: time.Hour,
+	}
+	f := &failingFetcher{forced: make(chan bool, 2)}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, f, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return len(s.NextRuns()) == NextRunCount
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// A failed forced run schedules a follow-up
+	_, err := s.Trigger(true, "manual")
+	require.Error(t, err)
+	assert.True(t, <-f.forced)
+
+	s.mu.Lock()
+	var followUp gocron.Job
+	for _, job := range s.scheduler.Jobs() {
+		if job.ID() == s.followUpJob {
+			followUp = job
+		}
+	}
+	s.mu.Unlock()
+	require.NotNil(t, followUp)
+
+	// The follow-up is not forced
+	require.NoError(t, followUp.RunNow())
+	select {
+	case forced := <-f.forced:
+		assert.False(t, forced)
+	case <-time.After(2 * time.Second):
+		t.Fatal("follow-up did not run")
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestTriggerHandler(t *testing.T) {
+	cfg := config.Config{
+		Timezone:      "UTC",
+		ScheduleTime:  "23:59",
+		OverlapPolicy: config.OverlapSkip,
+	}
+	f := &triggerFetcher{}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, f, logger)
+	handler := TriggerHandler(s)
+
+	// Not running yet
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/trigger", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return len(s.NextRuns()) == NextRunCount
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// A successful run returns its record
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/trigger?force=true", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var entry state.Entry
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entry))
+	assert.Equal(t, "192.168.1.1", entry.IP)
+	assert.True(t, entry.Forced)
+
+	// A failed run is reported with its error
+	f.err = errors.New("HTTP request failed")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/trigger", nil))
+	require.Equal(t, http.StatusBadGateway, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entry))
+	assert.Equal(t, "HTTP request failed", entry.Error)
+
+	// A run in progress is not overlapped
+	s.running.Lock()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/trigger", nil))
+	s.running.Unlock()
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "a run is already in progress")
+
+	// Bad requests
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/trigger?force=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/trigger", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestPauseHandler(t *testing.T) {
+	cfg := config.Config{
+		Timezone:      "UTC",
+		ScheduleTime:  "23:59",
+		OverlapPolicy: config.OverlapSkip,
+	}
+	f := &triggerFetcher{}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	var observed []error
+	s := New(cfg, f, logger, WithRunObserver(func(_ state.Entry, err error) { observed = append(observed, err) }))
+	pauseHandler := PauseHandler(s)
+	resumeHandler := ResumeHandler(s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return len(s.NextRuns()) == NextRunCount
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.True(t, s.Running())
+
+	// Pausing responds with the paused state
+	rec := httptest.NewRecorder()
+	pauseHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/pause?reason=router+swap&for=2h", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var pause state.Pause
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &pause))
+	assert.True(t, pause.Paused)
+	assert.Equal(t, "router swap", pause.Reason)
+	assert.NotEmpty(t, pause.Until)
+
+	// Manual runs are refused while paused
+	rec = httptest.NewRecorder()
+	TriggerHandler(s).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/trigger", nil))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "runs are paused: router swap")
+	assert.Empty(t, observed, "skipped runs are not observed")
+
+	// Resuming clears it
+	rec = httptest.NewRecorder()
+	resumeHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/resume", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &pause))
+	assert.False(t, pause.Paused)
+	assert.False(t, s.Status().Paused)
+
+	// Bad requests
+	rec = httptest.NewRecorder()
+	pauseHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/pause?for=soon", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	pauseHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/pause", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = httptest.NewRecorder()
+	resumeHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/resume", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.False(t, s.Running())
+}
+
+func TestRequireToken(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name          string
+		token         config.Secret
+		authorization string
+		expected      int
+	}{
+		{"valid token", "admin-token", "Bearer admin-token", http.StatusNoContent},
+		{"wrong token", "admin-token", "Bearer other-token", http.StatusUnauthorized},
+		{"token prefix", "admin-token", "Bearer admin", http.StatusUnauthorized},
+		{"missing header", "admin-token", "", http.StatusUnauthorized},
+		{"other scheme", "admin-token", "Basic admin-token", http.StatusUnauthorized},
+		{"no token configured", "", "Bearer ", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/trigger", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			rec := httptest.NewRecorder()
+			RequireToken(tt.token, next).ServeHTTP(rec, req)
+			assert.Equal(t, tt.expected, rec.Code, rec.Body.String())
+			if tt.expected == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -2,100 +2,779 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"strconv"
-	"strings"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Drakx/ZonoCaller/internal/config"
 	"github.com/Drakx/ZonoCaller/internal/fetcher"
+	"github.com/Drakx/ZonoCaller/internal/netwatch"
+	"github.com/Drakx/ZonoCaller/internal/state"
 	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
 )
 
 // Scheduler manages the periodic IP fetching and DNS updates
 type Scheduler struct {
+	mu        sync.Mutex
+	ctx       context.Context
 	config    config.Config
 	fetcher   fetcher.FetcherInterface
 	logger    *slog.Logger
 	scheduler gocron.Scheduler
+	schedule  config.Schedule
+	lastRun   func() (time.Time, error)
+	pauses    PauseStore
+	onRun     []func(result state.Entry, err error)
+
+	// pause is the current paused state, persisted to pauses if set
+	pause state.Pause
+
+	// running is held for the duration of a run; queued is set while a run
+	// waits for it under OVERLAP_POLICY=queue
+	running sync.Mutex
+	queued  atomic.Bool
+
+	// followUp is the current adaptive interval, 0 while stable, and
+	// followUpJob the pending adaptive run
+	followUp    time.Duration
+	followUpJob uuid.UUID
+}
+
+// ErrRunInProgress is returned by Trigger when the run was skipped because
+// another is in progress.
+var ErrRunInProgress = errors.New("a run is already in progress")
+
+// ErrNotRunning is returned by Trigger before Run has started the scheduler.
+var ErrNotRunning = errors.New("scheduler is not running")
+
+// ErrPaused is returned by Trigger when runs are paused.
+var ErrPaused = errors.New("runs are paused")
+
+// ErrMaintenance is returned by Trigger during a maintenance window.
+var ErrMaintenance = errors.New("in a maintenance window")
+
+// Job names in the gocron scheduler.
+const (
+	fetchJobName    = "fetch"
+	adaptiveJobName = "adaptive"
+)
+
+// resultReporter is implemented by fetchers that report the outcome of
+// their last run.
+type resultReporter interface {
+	LastResult() state.Entry
+}
+
+// Option configures a Scheduler
+type Option func(*Scheduler)
+
+// WithLastRun reads the start time of the last run from persisted state, so
+// a run missed while the service was stopped can be caught up at startup.
+func WithLastRun(lastRun func() (time.Time, error)) Option {
+	return func(s *Scheduler) {
+		s.lastRun = lastRun
+	}
+}
+
+// WithRunObserver calls observe after every run with its record, when the
+// fetcher reports one, and error. Skipped runs are not observed. The option
+// may be given more than once.
+func WithRunObserver(observe func(result state.Entry, err error)) Option {
+	return func(s *Scheduler) {
+		s.onRun = append(s.onRun, observe)
+	}
+}
+
+// PauseStore persists the paused state across restarts.
+type PauseStore interface {
+	PauseState() (state.Pause, error)
+	SetPauseState(pause state.Pause) error
+}
+
+// WithPauseStore loads the paused state from store when the scheduler runs
+// and records pauses and resumes to it.
+func WithPauseStore(store PauseStore) Option {
+	return func(s *Scheduler) {
+		s.pauses = store
+	}
+}
+
+// NextRunCount is the number of upcoming runs reported in logs and status.
+const NextRunCount = 3
+
+// Status describes the active schedule.
+type Status struct {
+	Timezone string      `json:"timezone"`
+	Schedule string      `json:"schedule"`
+	NextRuns []time.Time `json:"next_runs"`
+	// AdaptiveRun is the pending adaptive follow-up run, if any
+	AdaptiveRun *time.Time `json:"adaptive_run,omitempty"`
+	Paused      bool       `json:"paused"`
+	PauseReason string     `json:"pause_reason,omitempty"`
+	PausedUntil *time.Time `json:"paused_until,omitempty"`
+	// Maintenance is the maintenance window in effect, if any
+	Maintenance string `json:"maintenance,omitempty"`
 }
 
 // New creates a new Scheduler instance
-func New(cfg config.Config, f fetcher.FetcherInterface, logger *slog.Logger) *Scheduler {
-	return &Scheduler{
+func New(cfg config.Config, f fetcher.FetcherInterface, logger *slog.Logger, opts ...Option) *Scheduler {
+
+	s := &Scheduler{
 		config:  cfg,
 		fetcher: f,
 		logger:  logger,
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 // Run starts the scheduler
 func (s *Scheduler) Run(ctx context.Context) error {
 
-	if s.config.RunOnce {
+	s.mu.Lock()
+	cfg := s.config
+	s.mu.Unlock()
+
+	s.loadPause()
+
+	if cfg.RunOnce {
+		if err := s.suspended(time.Now()); err != nil {
+			s.logger.Info("Skipping run", "reason", "run once", "cause", err.Error())
+			return nil
+		}
+
 		s.logger.Info("Running fetcher once")
-		return s.fetcher.FetchIP(ctx)
+		if cfg.RunTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, cfg.RunTimeout)
+			defer cancel()
+		}
+		err := s.fetcher.FetchIP(ctx)
+		s.observe(err)
+		return err
 	}
 
-	s.logger.Info("Starting scheduler", "timezone", s.config.Timezone, "schedule", s.config.ScheduleTime)
-
-	loc, err := time.LoadLocation(s.config.Timezone)
+	scheduler, schedule, err := s.newScheduler(ctx, cfg)
 	if err != nil {
-		return fmt.Errorf("failed to load timezone %s: %w", s.config.Timezone, err)
+		return err
+	}
+
+	s.mu.Lock()
+	s.ctx = ctx
+	s.scheduler = scheduler
+	s.schedule = schedule
+	scheduler.Start()
+	s.mu.Unlock()
+
+	s.logger.Info("Starting scheduler", "timezone", cfg.Timezone, "schedule", schedule.String(), "next_runs", s.NextRuns())
+
+	// Also run shortly after the host's network changes
+	if cfg.NetworkWatch {
+		go s.watchNetwork(ctx, cfg.NetworkWatchDebounce)
 	}
 
-	// Parse ScheduleTime (e.g., "23:59") into hour and minute
-	parts := strings.Split(s.config.ScheduleTime, ":")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid SCHEDULE_TIME format: %s, expected HH:MM", s.config.ScheduleTime)
+	// Run now if configured to, or if a scheduled run was missed while stopped
+	if reason := s.startupReason(cfg, schedule, time.Now()); reason != "" {
+		s.fetch(ctx, reason)
 	}
 
-	hour, err := strconv.Atoi(parts[0])
+	<-ctx.Done()
+
+	// Running jobs take s.mu, so wait for them without holding it
+	s.mu.Lock()
+	scheduler = s.scheduler
+	s.mu.Unlock()
+
+	if err := scheduler.Shutdown(); err != nil {
+		s.logger.Error("Failed to shutdown scheduler", "error", err)
+	}
+	return nil
+}
+
+// Reload swaps in a new configuration. If the schedule or timezone changed
+// while the scheduler is running, the job is rescheduled; on error the
+// current schedule and configuration are kept.
+func (s *Scheduler) Reload(cfg config.Config) error {
+
+	apply, err := s.PrepareReload(cfg)
 	if err != nil {
-		return fmt.Errorf("invalid SCHEDULE_TIME hour: %w", err)
+		return err
 	}
 
-	minute, err := strconv.Atoi(parts[1])
+	apply()
+	return nil
+}
+
+// PrepareReload checks that cfg can be swapped in, building the new schedule
+// if it changed, and returns a function that swaps it in. That function
+// cannot fail, so callers can prepare every component before changing any.
+func (s *Scheduler) PrepareReload(cfg config.Config) (func(), error) {
+
+	s.mu.Lock()
+	var scheduler gocron.Scheduler
+	var schedule config.Schedule
+	if s.scheduler != nil && s.ctx != nil && s.ctx.Err() == nil &&
+		(cfg.Timezone != s.config.Timezone || cfg.ScheduleTime != s.config.ScheduleTime || cfg.Schedule != s.config.Schedule) {
+
+		var err error
+		if scheduler, schedule, err = s.newScheduler(s.ctx, cfg); err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+	s.mu.Unlock()
+
+	return func() {
+
+		s.mu.Lock()
+
+		var old gocron.Scheduler
+		if scheduler != nil {
+			old = scheduler
+
+			// Swap only while running; otherwise drop the new scheduler
+			if s.ctx.Err() == nil {
+				old = s.scheduler
+				scheduler.Start()
+				s.scheduler = scheduler
+				s.schedule = schedule
+
+				// A pending adaptive run was dropped with the old scheduler
+				s.followUpJob = uuid.Nil
+
+				s.logger.Info("Rescheduled", "timezone", cfg.Timezone, "schedule", schedule.String(), "next_runs", s.nextRuns())
+			}
+		}
+
+		s.config = cfg
+		s.mu.Unlock()
+
+		// Running jobs take s.mu, so wait for them without holding it
+		if old != nil {
+			if err := old.Shutdown(); err != nil {
+				s.logger.Error("Failed to shutdown scheduler", "error", err)
+			}
+		}
+	}, nil
+}
+
+// Status returns the active schedule and its next run times. Before Run
+// starts the scheduler, only the configured schedule is reported.
+func (s *Scheduler) Status() Status {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := Status{Timezone: s.config.Timezone, Schedule: s.schedule.String(), NextRuns: s.nextRuns()}
+
+	now := time.Now()
+	if pause := s.activePause(now); pause.Paused {
+		status.Paused = true
+		status.PauseReason = pause.Reason
+		if until, err := time.Parse(time.RFC3339, pause.Until); err == nil {
+			status.PausedUntil = &until
+		}
+	}
+	if window, ok := s.maintenanceWindow(now); ok {
+		status.Maintenance = window.String()
+	}
+
+	if s.scheduler == nil {
+		if schedule, err := s.config.JobSchedule(); err == nil {
+			status.Schedule = schedule.String()
+		}
+		return status
+	}
+
+	for _, job := range s.scheduler.Jobs() {
+		if job.ID() != s.followUpJob {
+			continue
+		}
+		if next, err := job.NextRun(); err == nil && !next.IsZero() {
+			status.AdaptiveRun = &next
+		}
+	}
+
+	return status
+}
+
+// Running reports whether the scheduler has started and not yet stopped.
+func (s *Scheduler) Running() bool {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.scheduler != nil && s.ctx != nil && s.ctx.Err() == nil
+}
+
+// NextRuns returns the next NextRunCount run times of the fetch job.
+func (s *Scheduler) NextRuns() []time.Time {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.nextRuns()
+}
+
+// nextRuns returns the next run times. The caller must hold s.mu.
+func (s *Scheduler) nextRuns() []time.Time {
+
+	if s.scheduler == nil {
+		return []time.Time{}
+	}
+
+	for _, job := range s.scheduler.Jobs() {
+		if job.Name() != fetchJobName {
+			continue
+		}
+
+		runs, err := job.NextRuns(NextRunCount)
+		if err != nil {
+			s.logger.Warn("Failed to get next run times", "error", err)
+			break
+		}
+		return runs
+	}
+
+	return []time.Time{}
+}
+
+// newScheduler creates a gocron scheduler with the fetch job for cfg, whose
+// job context ends with ctx or when the scheduler shuts down. The scheduler
+// is not started.
+func (s *Scheduler) newScheduler(ctx context.Context, cfg config.Config) (gocron.Scheduler, config.Schedule, error) {
+
+	loc, err := time.LoadLocation(cfg.Timezone)
 	if err != nil {
-		return fmt.Errorf("invalid SCHEDULE_TIME minute: %w", err)
+		return nil, config.Schedule{}, fmt.Errorf("failed to load timezone %s: %w", cfg.Timezone, err)
 	}
 
-	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
-		return fmt.Errorf("invalid SCHEDULE_TIME: hour must be 0-23, minute must be 0-59, got %d:%d", hour, minute)
+	// SCHEDULE, or daily at SCHEDULE_TIME (e.g., "23:59")
+	schedule, err := cfg.JobSchedule()
+	if err != nil {
+		return nil, config.Schedule{}, err
 	}
 
 	scheduler, err := gocron.NewScheduler(gocron.WithLocation(loc))
 	if err != nil {
-		return fmt.Errorf("failed to create scheduler: %w", err)
+		return nil, config.Schedule{}, fmt.Errorf("failed to create scheduler: %w", err)
 	}
 
-	s.scheduler = scheduler
-
 	_, err = scheduler.NewJob(
-		gocron.DailyJob(
-			1,
-			gocron.NewAtTimes(
-				gocron.NewAtTime(uint(hour), uint(minute), 0),
-			),
-		),
-		gocron.NewTask(
-			func() {
-				s.logger.Info("Running scheduled IP fetch")
-				if err := s.fetcher.FetchIP(ctx); err != nil {
-					s.logger.Error("Failed to fetch IP", "error", err)
-				}
-			},
-		),
+		jobDefinition(schedule),
+		gocron.NewTask(s.scheduled),
+		gocron.WithContext(ctx),
+		gocron.WithName(fetchJobName),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to schedule job: %w", err)
+		scheduler.Shutdown()
+		return nil, config.Schedule{}, fmt.Errorf("failed to schedule job: %w", err)
 	}
 
-	scheduler.Start()
-	<-ctx.Done()
-	if err := scheduler.Shutdown(); err != nil {
-		s.logger.Error("Failed to shutdown scheduler", "error", err)
+	return scheduler, schedule, nil
+}
+
+// Pause skips runs until Resume is called or, if duration is positive, until
+// it has passed. The paused state is persisted to the pause store.
+func (s *Scheduler) Pause(reason string, duration time.Duration) (state.Pause, error) {
+
+	now := time.Now()
+	pause := state.Pause{Paused: true, Reason: reason, Since: now.UTC().Format(time.RFC3339)}
+	if duration > 0 {
+		pause.Until = now.Add(duration).UTC().Format(time.RFC3339)
 	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pauses != nil {
+		if err := s.pauses.SetPauseState(pause); err != nil {
+			return state.Pause{}, err
+		}
+	}
+	s.pause = pause
+
+	s.logger.Info("Paused runs", "pause_reason", reason, "until", pause.Until)
+	return pause, nil
+}
+
+// Resume ends a pause.
+func (s *Scheduler) Resume() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pauses != nil {
+		if err := s.pauses.SetPauseState(state.Pause{}); err != nil {
+			return err
+		}
+	}
+
+	if s.pause.Paused {
+		s.logger.Info("Resumed runs")
+	}
+	s.pause = state.Pause{}
+
 	return nil
 }
+
+// loadPause restores the paused state from the pause store.
+func (s *Scheduler) loadPause() {
+
+	if s.pauses == nil {
+		return
+	}
+
+	pause, err := s.pauses.PauseState()
+	if err != nil {
+		s.logger.Error("Failed to read pause state", "error", err)
+		return
+	}
+
+	s.mu.Lock()
+	s.pause = pause
+	s.mu.Unlock()
+
+	if pause.Paused {
+		s.logger.Info("Runs are paused", "pause_reason", pause.Reason, "since", pause.Since, "until", pause.Until)
+	}
+}
+
+// activePause returns the paused state at now, resuming once a timed pause
+// has expired. The caller must hold s.mu.
+func (s *Scheduler) activePause(now time.Time) state.Pause {
+
+	if !s.pause.Paused || s.pause.Until == "" {
+		return s.pause
+	}
+
+	until, err := time.Parse(time.RFC3339, s.pause.Until)
+	if err != nil || now.Before(until) {
+		return s.pause
+	}
+
+	s.logger.Info("Pause expired, resuming runs", "until", s.pause.Until)
+	s.pause = state.Pause{}
+	if s.pauses != nil {
+		if err := s.pauses.SetPauseState(s.pause); err != nil {
+			s.logger.Error("Failed to record pause state", "error", err)
+		}
+	}
+
+	return s.pause
+}
+
+// maintenanceWindow returns the maintenance window containing now, if any.
+// The caller must hold s.mu.
+func (s *Scheduler) maintenanceWindow(now time.Time) (config.MaintenanceWindow, bool) {
+
+	windows, err := s.config.ParseMaintenanceWindows()
+	if err != nil {
+		s.logger.Warn("Failed to parse maintenance windows", "error", err)
+		return config.MaintenanceWindow{}, false
+	}
+
+	loc, err := time.LoadLocation(s.config.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	for _, window := range windows {
+		if window.Contains(now, loc) {
+			return window, true
+		}
+	}
+
+	return config.MaintenanceWindow{}, false
+}
+
+// suspended returns ErrPaused or ErrMaintenance if runs are skipped at now.
+func (s *Scheduler) suspended(now time.Time) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pause := s.activePause(now); pause.Paused {
+		if pause.Reason != "" {
+			return fmt.Errorf("%w: %s", ErrPaused, pause.Reason)
+		}
+		return ErrPaused
+	}
+
+	if window, ok := s.maintenanceWindow(now); ok {
+		return fmt.Errorf("%w %s", ErrMaintenance, window)
+	}
+
+	return nil
+}
+
+// Trigger runs the fetch job now, subject to OVERLAP_POLICY, and returns the
+// run's record. With force, DNS is updated even if the IP is unchanged. The
+// run is bound to the scheduler's lifetime, not the caller's.
+func (s *Scheduler) Trigger(force bool, reason string) (state.Entry, error) {
+
+	s.mu.Lock()
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	if ctx == nil {
+		return state.Entry{}, ErrNotRunning
+	}
+
+	if force {
+		ctx = fetcher.ForceUpdate(ctx)
+	}
+
+	return s.fetch(ctx, reason)
+}
+
+// fetch runs the fetcher once, logging why and any failure, and returns the
+// run's record when the fetcher reports one. Runs are skipped while paused
+// or in a maintenance window. Runs never overlap: a run that is due while
+// another is in progress is skipped or, with OVERLAP_POLICY=queue, made once
+// the current run finishes. Each run is cancelled after RUN_TIMEOUT.
+func (s *Scheduler) fetch(ctx context.Context, reason string) (state.Entry, error) {
+
+	if err := s.suspended(time.Now()); err != nil {
+		s.logger.Info("Skipping run", "reason", reason, "cause", err.Error())
+		return state.Entry{}, err
+	}
+
+	s.mu.Lock()
+	cfg := s.config
+	s.mu.Unlock()
+
+	if !s.running.TryLock() {
+		if cfg.OverlapPolicy != config.OverlapQueue || !s.queued.CompareAndSwap(false, true) {
+			s.logger.Warn("Previous run still in progress, skipping run", "reason", reason)
+			return state.Entry{}, ErrRunInProgress
+		}
+
+		s.logger.Info("Previous run still in progress, queueing run", "reason", reason)
+		s.running.Lock()
+		s.queued.Store(false)
+
+		// Don't start a queued run during shutdown
+		if ctx.Err() != nil {
+			s.running.Unlock()
+			return state.Entry{}, ctx.Err()
+		}
+	}
+	defer s.running.Unlock()
+
+	// Follow-up runs outlive this run's timeout
+	runCtx := ctx
+	if cfg.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, cfg.RunTimeout)
+		defer cancel()
+	}
+
+	s.logger.Info("Running IP fetch", "reason", reason)
+	err := s.fetcher.FetchIP(runCtx)
+	if err != nil {
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			s.logger.Error("Run timed out", "timeout", cfg.RunTimeout, "error", err)
+		} else {
+			s.logger.Error("Failed to fetch IP", "error", err)
+		}
+	}
+
+	result := s.observe(err)
+
+	if cfg.AdaptiveSchedule {
+		s.adapt(cfg, result.Changed || err != nil)
+	}
+
+	return result, err
+}
+
+// observe passes the outcome of the run that just finished to the run
+// observers, and returns its record when the fetcher reports one.
+func (s *Scheduler) observe(err error) state.Entry {
+
+	var result state.Entry
+	if reporter, ok := s.fetcher.(resultReporter); ok {
+		result = reporter.LastResult()
+	}
+
+	for _, observe := range s.onRun {
+		observe(result, err)
+	}
+
+	return result
+}
+
+// watchNetwork runs the fetch job after network address or default route
+// changes, once the network has been quiet for debounce.
+func (s *Scheduler) watchNetwork(ctx context.Context, debounce time.Duration) {
+
+	s.logger.Info("Watching for network changes", "debounce", debounce)
+
+	err := netwatch.Watch(ctx, debounce, func(event string) {
+		s.logger.Info("Network changed", "event", event)
+		s.fetch(ctx, "network change")
+	})
+	if err != nil {
+		s.logger.Error("Failed to watch for network changes, following the schedule only", "error", err)
+	}
+}
+
+// scheduled runs the fetch job for the schedule, after a random delay of up
+// to SCHEDULE_JITTER so deployments sharing a schedule don't all call the
+// APIs at the same moment. The delay ends with the job context, so a
+// shutdown or reschedule drops the run; the run itself is bound to the
+// scheduler's lifetime, so a reschedule doesn't cancel it.
+func (s *Scheduler) scheduled(ctx context.Context) {
+
+	s.mu.Lock()
+	jitter := s.config.ScheduleJitter
+	runCtx := s.ctx
+	s.mu.Unlock()
+
+	if jitter > 0 {
+		delay := rand.N(jitter)
+		s.logger.Info("Delaying scheduled run", "delay", delay)
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+	}
+
+	s.fetch(runCtx, "schedule")
+}
+
+// adapt schedules an extra run after a change or failure, at
+// ADAPTIVE_MIN_INTERVAL, and doubles the interval after each uneventful run
+// until it passes ADAPTIVE_MAX_INTERVAL and the regular schedule takes over.
+// Extra runs are bound to the scheduler's lifetime, so they are not forced
+// when the run that scheduled them was.
+func (s *Scheduler) adapt(cfg config.Config, eventful bool) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.followUp = nextFollowUp(s.followUp, cfg.AdaptiveMinInterval, cfg.AdaptiveMaxInterval, eventful)
+
+	if s.scheduler == nil || s.ctx == nil {
+		return
+	}
+
+	if s.followUpJob != uuid.Nil {
+		// The job is gone once it has run
+		_ = s.scheduler.RemoveJob(s.followUpJob)
+		s.followUpJob = uuid.Nil
+	}
+
+	if s.followUp == 0 {
+		return
+	}
+
+	// No extra run is needed if the schedule runs sooner anyway
+	at := time.Now().Add(s.followUp)
+	if runs := s.nextRuns(); len(runs) > 0 && !runs[0].After(at) {
+		return
+	}
+
+	job, err := s.scheduler.NewJob(
+		gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(at)),
+		gocron.NewTask(s.fetch, s.ctx, "adaptive"),
+		gocron.WithName(adaptiveJobName),
+	)
+	if err != nil {
+		s.logger.Error("Failed to schedule adaptive run", "error", err)
+		return
+	}
+	s.followUpJob = job.ID()
+
+	s.logger.Info("Scheduled adaptive run", "interval", s.followUp, "at", at)
+}
+
+// nextFollowUp returns the adaptive interval after a run: minInterval after
+// a change or failure, otherwise double the current interval, or 0 once that
+// exceeds maxInterval or while stable.
+func nextFollowUp(current, minInterval, maxInterval time.Duration, eventful bool) time.Duration {
+
+	if eventful {
+		return minInterval
+	}
+
+	if current == 0 || current*2 > maxInterval {
+		return 0
+	}
+
+	return current * 2
+}
+
+// startupReason returns why a run should happen immediately at startup, or
+// "" if the schedule should simply be followed. With CATCH_UP, a run is made
+// when the first scheduled time after the persisted last run has passed, or
+// when no run was ever recorded.
+func (s *Scheduler) startupReason(cfg config.Config, schedule config.Schedule, now time.Time) string {
+
+	if cfg.RunOnStartup {
+		return "startup"
+	}
+
+	if !cfg.CatchUp {
+		return ""
+	}
+
+	var lastRun time.Time
+	if s.lastRun != nil {
+		var err error
+		if lastRun, err = s.lastRun(); err != nil {
+			s.logger.Warn("Failed to read last run time, catching up", "error", err)
+			return "catch-up"
+		}
+	}
+
+	if lastRun.IsZero() {
+		s.logger.Info("No previous run recorded, catching up")
+		return "catch-up"
+	}
+
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	missed := schedule.Next(lastRun.In(loc))
+	if missed.After(now) {
+		return ""
+	}
+
+	s.logger.Info("Missed scheduled run, catching up", "last_run", lastRun, "missed_run", missed)
+	return "catch-up"
+}
+
+// jobDefinition converts a schedule into a gocron job definition.
+func jobDefinition(schedule config.Schedule) gocron.JobDefinition {
+
+	switch {
+	case schedule.Cron != "":
+		return gocron.CronJob(schedule.Cron, false)
+	case schedule.Interval > 0:
+		return gocron.DurationJob(schedule.Interval)
+	default:
+		atTimes := make([]gocron.AtTime, len(schedule.Times))
+		for i, at := range schedule.Times {
+			atTimes[i] = gocron.NewAtTime(uint(at.Hour), uint(at.Minute), 0)
+		}
+		return gocron.DailyJob(1, gocron.NewAtTimes(atTimes[0], atTimes[1:]...))
+	}
+}
@@ -6,10 +6,12 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/Drakx/ZonoCaller/internal/state"
 	"github.com/go-co-op/gocron/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -46,7 +48,8 @@ func TestRun_RunOnce(t *testing.T) {
 	}
 	f := &mockFetcher{}
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
-	s := New(cfg, f, logger)
+	var observed int
+	s := New(cfg, f, logger, WithRunObserver(func(_ state.Entry, _ error) { observed++ }))
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -54,6 +57,8 @@ func TestRun_RunOnce(t *testing.T) {
 	err := s.Run(ctx)
 	require.NoError(t, err)
 	assert.True(t, f.fetchCalled, "FetchIP should be called in run-once mode")
+	assert.Equal(t, 1, observed)
+	assert.False(t, s.Running())
 }
 
 func TestRun_Scheduled(t *testing.T) {
@@ -162,3 +167,623 @@ func TestRun_InvalidScheduleTime(t *testing.T) {
 		})
 	}
 }
+
+func TestReload_NotRunning(t *testing.T) {
+	cfg := config.Config{
+		Timezone:     "UTC",
+		ScheduleTime: "23:59",
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, &mockFetcher{}, logger)
+
+	// Reloading before Run only swaps the config
+	cfg.ScheduleTime = "06:00"
+	require.NoError(t, s.Reload(cfg))
+	assert.Equal(t, cfg, s.config)
+	assert.Nil(t, s.scheduler)
+}
+
+func TestReload_Reschedules(t *testing.T) {
+	cfg := config.Config{
+		Timezone:     "UTC",
+		ScheduleTime: "23:59",
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, &mockFetcher{}, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+
+	// Wait for the scheduler to start
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.scheduler != nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	s.mu.Lock()
+	original := s.scheduler
+	s.mu.Unlock()
+
+	// A new schedule replaces the running gocron scheduler
+	cfg.ScheduleTime = "06:00"
+	require.NoError(t, s.Reload(cfg))
+
+	s.mu.Lock()
+	assert.NotSame(t, original, s.scheduler)
+	assert.Equal(t, "06:00", s.config.ScheduleTime)
+	jobs := s.scheduler.Jobs()
+	s.mu.Unlock()
+
+	require.Len(t, jobs, 1)
+	next, err := jobs[0].NextRun()
+	require.NoError(t, err)
+	assert.Equal(t, 6, next.UTC().Hour())
+
+	// An invalid timezone keeps the current schedule
+	bad := cfg
+	bad.Timezone = "Invalid/Timezone"
+	require.Error(t, s.Reload(bad))
+	assert.Equal(t, "UTC", s.config.Timezone)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestPrepareReload(t *testing.T) {
+	cfg := config.Config{
+		Timezone:     "UTC",
+		ScheduleTime: "23:59",
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, &mockFetcher{}, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(s.NextRuns()) == NextRunCount
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// An invalid schedule is refused before anything changes
+	bad := cfg
+	bad.Timezone = "Invalid/Timezone"
+	_, err := s.PrepareReload(bad)
+	require.Error(t, err)
+
+	// A valid one is only swapped in when applied
+	cfg.ScheduleTime = "06:00"
+	apply, err := s.PrepareReload(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 23, s.NextRuns()[0].UTC().Hour())
+
+	apply()
+	assert.Equal(t, 6, s.NextRuns()[0].UTC().Hour())
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestReload_WaitsForRunningJobWithoutLock(t *testing.T) {
+	cfg := config.Config{
+		Timezone:            "UTC",
+		ScheduleTime:        "23:59",
+		AdaptiveSchedule:    true,
+		AdaptiveMinInterval: time.Minute,
+		AdaptiveMaxInterval: time.Hour,
+	}
+	f := newBlockingFetcher()
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, f, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.scheduler != nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// Start the fetch job inside the gocron scheduler
+	s.mu.Lock()
+	jobs := s.scheduler.Jobs()
+	s.mu.Unlock()
+	require.Len(t, jobs, 1)
+	require.NoError(t, jobs[0].RunNow())
+	<-f.started
+
+	// Shutting down the old scheduler waits for the job, which takes s.mu
+	// once the fetch finishes
+	cfg.ScheduleTime = "06:00"
+	reloaded := make(chan error)
+	go func() {
+		reloaded <- s.Reload(cfg)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(f.release)
+
+	select {
+	case err := <-reloaded:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reload did not return while a job was running")
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestRun_Schedules(t *testing.T) {
+	tests := []struct {
+		schedule string
+		check    func(t *testing.T, runs []time.Time)
+	}{
+		{
+			schedule: "*/15 * * * *",
+			check: func(t *testing.T, runs []time.Time) {
+				for _, run := range runs {
+					assert.Zero(t, run.Minute()%15)
+				}
+				assert.Equal(t, 15*time.Minute, runs[1].Sub(runs[0]))
+			},
+		},
+		{
+			schedule: "10m",
+			check: func(t *testing.T, runs []time.Time) {
+				assert.Equal(t, 10*time.Minute, runs[1].Sub(runs[0]))
+			},
+		},
+		{
+			schedule: "06:00,18:00",
+			check: func(t *testing.T, runs []time.Time) {
+				for _, run := range runs {
+					assert.Contains(t, []int{6, 18}, run.UTC().Hour())
+				}
+				assert.Equal(t, 12*time.Hour, runs[1].Sub(runs[0]))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.schedule, func(t *testing.T) {
+			cfg := config.Config{
+				Timezone:     "UTC",
+				ScheduleTime: "23:59",
+				Schedule:     tt.schedule,
+			}
+			logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+			s := New(cfg, &mockFetcher{}, logger)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				done <- s.Run(ctx)
+			}()
+
+			// Wait for the scheduler to start
+			require.Eventually(t, func() bool {
+				return len(s.NextRuns()) == NextRunCount
+			}, 2*time.Second, 10*time.Millisecond)
+
+			status := s.Status()
+			assert.Equal(t, "UTC", status.Timezone)
+			require.Len(t, status.NextRuns, NextRunCount)
+			assert.True(t, status.NextRuns[0].After(time.Now()))
+			tt.check(t, status.NextRuns)
+
+			cancel()
+			require.NoError(t, <-done)
+		})
+	}
+}
+
+func TestStatus_NotRunning(t *testing.T) {
+	cfg := config.Config{
+		Timezone:     "UTC",
+		ScheduleTime: "23:59",
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, &mockFetcher{}, logger)
+
+	status := s.Status()
+	assert.Equal(t, "daily at 23:59", status.Schedule)
+	assert.Empty(t, status.NextRuns)
+}
+
+func TestRun_RunOnStartup(t *testing.T) {
+	cfg := config.Config{
+		Timezone:     "UTC",
+		ScheduleTime: "23:59",
+		RunOnStartup: true,
+	}
+	f := &mockFetcher{}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, f, logger)
+
+	// The startup run happens before Run waits for cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, s.Run(ctx))
+	assert.True(t, f.fetchCalled, "FetchIP should be called on startup")
+}
+
+func TestStartupReason(t *testing.T) {
+	now := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	daily := config.Schedule{Times: []config.TimeOfDay{{Hour: 6, Minute: 0}}}
+
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		schedule config.Schedule
+		lastRun  time.Time
+		expected string
+	}{
+		{"disabled", config.Config{}, daily, time.Time{}, ""},
+		{"run on startup", config.Config{RunOnStartup: true}, daily, now, "startup"},
+		{"never run", config.Config{CatchUp: true}, daily, time.Time{}, "catch-up"},
+		{"ran after last scheduled time", config.Config{CatchUp: true}, daily, now.Add(-5 * time.Hour), ""},
+		{"missed this morning", config.Config{CatchUp: true}, daily, now.Add(-7 * time.Hour), "catch-up"},
+		{"interval not yet due", config.Config{CatchUp: true}, config.Schedule{Interval: time.Hour}, now.Add(-30 * time.Minute), ""},
+		{"interval missed", config.Config{CatchUp: true}, config.Schedule{Interval: time.Hour}, now.Add(-2 * time.Hour), "catch-up"},
+		{"cron missed", config.Config{CatchUp: true}, config.Schedule{Cron: "0 * * * *"}, now.Add(-61 * time.Minute), "catch-up"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Timezone = "UTC"
+			logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+			s := New(tt.cfg, &mockFetcher{}, logger, WithLastRun(func() (time.Time, error) {
+				return tt.lastRun, nil
+			}))
+
+			assert.Equal(t, tt.expected, s.startupReason(tt.cfg, tt.schedule, now))
+		})
+	}
+}
+
+// blockingFetcher blocks each FetchIP call until released or ctx is done.
+type blockingFetcher struct {
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+	err     chan error
+}
+
+func newBlockingFetcher() *blockingFetcher {
+	return &blockingFetcher{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+		err:     make(chan error, 10),
+	}
+}
+
+func (b *blockingFetcher) FetchIP(ctx context.Context) error {
+	b.calls.Add(1)
+	b.started <- struct{}{}
+
+	var err error
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	b.err <- err
+	return err
+}
+
+func TestFetch_Overlap(t *testing.T) {
+	tests := []struct {
+		policy   string
+		expected int32
+	}{
+		{config.OverlapSkip, 1},
+		{config.OverlapQueue, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			cfg := config.Config{OverlapPolicy: tt.policy}
+			f := newBlockingFetcher()
+			logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+			s := New(cfg, f, logger)
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.fetch(context.Background(), "schedule")
+			}()
+			<-f.started
+
+			// With queueing, one run waits and any further ones are skipped
+			if tt.policy == config.OverlapQueue {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.fetch(context.Background(), "schedule")
+				}()
+				require.Eventually(t, s.queued.Load, 2*time.Second, 10*time.Millisecond)
+			}
+			s.fetch(context.Background(), "schedule")
+			s.fetch(context.Background(), "schedule")
+
+			close(f.release)
+			wg.Wait()
+			assert.Equal(t, tt.expected, f.calls.Load())
+		})
+	}
+}
+
+func TestFetch_RunTimeout(t *testing.T) {
+	cfg := config.Config{OverlapPolicy: config.OverlapSkip, RunTimeout: 50 * time.Millisecond}
+	f := newBlockingFetcher()
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, f, logger)
+
+	// The run is cancelled through its context
+	s.fetch(context.Background(), "schedule")
+	assert.ErrorIs(t, <-f.err, context.DeadlineExceeded)
+}
+
+func TestNextFollowUp(t *testing.T) {
+	minInterval, maxInterval := 5*time.Minute, time.Hour
+
+	// A change starts follow-ups, which back off until the schedule takes over
+	var intervals []time.Duration
+	current := nextFollowUp(0, minInterval, maxInterval, true)
+	for current != 0 {
+		intervals = append(intervals, current)
+		current = nextFollowUp(current, minInterval, maxInterval, false)
+	}
+	assert.Equal(t, []time.Duration{5 * time.Minute, 10 * time.Minute, 20 * time.Minute, 40 * time.Minute}, intervals)
+
+	// A failure while backing off starts again at the minimum
+	assert.Equal(t, minInterval, nextFollowUp(20*time.Minute, minInterval, maxInterval, true))
+
+	// Stable runs don't schedule follow-ups
+	assert.Zero(t, nextFollowUp(0, minInterval, maxInterval, false))
+}
+
+// reportingFetcher reports a fixed outcome for every run, and fails runs
+// whose context is already done.
+type reportingFetcher struct {
+	changed bool
+	calls   atomic.Int32
+	lastErr atomic.Value
+}
+
+func (r *reportingFetcher) FetchIP(ctx context.Context) error {
+	r.calls.Add(1)
+	err := ctx.Err()
+	r.lastErr.Store(errBox{err})
+	return err
+}
+
+// errBox lets atomic.Value hold a nil error.
+type errBox struct{ error }
+
+func (r *reportingFetcher) LastResult() state.Entry {
+	return state.Entry{Changed: r.changed}
+}
+
+func TestFetch_Adaptive(t *testing.T) {
+	cfg := config.Config{
+		Timezone:            "UTC",
+		Schedule:            "0 0 1 1 *",
+		OverlapPolicy:       config.OverlapSkip,
+		RunTimeout:          time.Minute,
+		AdaptiveSchedule:    true,
+		AdaptiveMinInterval: 5 * time.Minute,
+		AdaptiveMaxInterval: time.Hour,
+	}
+	f := &reportingFetcher{changed: true}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, f, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return len(s.NextRuns()) == NextRunCount
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// A change schedules a follow-up at the minimum interval
+	start := time.Now()
+	s.fetch(ctx, "schedule")
+	status := s.Status()
+	require.NotNil(t, status.AdaptiveRun)
+	assert.WithinDuration(t, start.Add(5*time.Minute), *status.AdaptiveRun, 5*time.Second)
+
+	// An unchanged run backs off
+	f.changed = false
+	s.fetch(ctx, "adaptive")
+	status = s.Status()
+	require.NotNil(t, status.AdaptiveRun)
+	assert.WithinDuration(t, start.Add(10*time.Minute), *status.AdaptiveRun, 5*time.Second)
+
+	// The regular schedule is unaffected
+	assert.Equal(t, time.January, status.NextRuns[0].Month())
+
+	// The follow-up run is not bound by the previous run's timeout
+	s.mu.Lock()
+	var followUp gocron.Job
+	for _, job := range s.scheduler.Jobs() {
+		if job.ID() == s.followUpJob {
+			followUp = job
+		}
+	}
+	s.mu.Unlock()
+	require.NotNil(t, followUp)
+	require.NoError(t, followUp.RunNow())
+	require.Eventually(t, func() bool {
+		return f.calls.Load() == 3
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.NoError(t, f.lastErr.Load().(errBox).error)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestScheduled_Jitter(t *testing.T) {
+	cfg := config.Config{OverlapPolicy: config.OverlapSkip, ScheduleJitter: time.Hour}
+	f := &mockFetcher{}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, f, logger)
+
+	// A cancelled run is dropped while waiting out the jitter
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.scheduled(ctx)
+	assert.False(t, f.fetchCalled)
+
+	// Without jitter the run starts straight away
+	s.ctx = context.Background()
+	s.config.ScheduleJitter = 0
+	s.scheduled(context.Background())
+	assert.True(t, f.fetchCalled)
+}
+
+func TestScheduled_JitterReschedule(t *testing.T) {
+	cfg := config.Config{Timezone: "UTC", ScheduleTime: "23:59", OverlapPolicy: config.OverlapSkip, ScheduleJitter: time.Hour}
+	f := &mockFetcher{}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, f, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- s.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		return len(s.NextRuns()) == NextRunCount
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// Start a scheduled run, which waits out the jitter
+	s.mu.Lock()
+	jobs := s.scheduler.Jobs()
+	s.mu.Unlock()
+	require.Len(t, jobs, 1)
+	require.NoError(t, jobs[0].RunNow())
+	time.Sleep(50 * time.Millisecond)
+
+	// Rescheduling shuts the old scheduler down without waiting for it
+	cfg.ScheduleTime = "22:00"
+	start := time.Now()
+	require.NoError(t, s.Reload(cfg))
+	assert.Less(t, time.Since(start), time.Second)
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.False(t, f.fetchCalled)
+}
+
+func TestPause(t *testing.T) {
+	cfg := config.Config{Timezone: "UTC", ScheduleTime: "23:59", OverlapPolicy: config.OverlapSkip}
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "ip_log.log"))
+	f := &mockFetcher{}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, f, logger, WithPauseStore(store))
+
+	// Runs are skipped while paused
+	pause, err := s.Pause("router swap", 0)
+	require.NoError(t, err)
+	assert.True(t, pause.Paused)
+	assert.Empty(t, pause.Until)
+
+	_, err = s.fetch(context.Background(), "schedule")
+	assert.ErrorIs(t, err, ErrPaused)
+	assert.Contains(t, err.Error(), "router swap")
+	assert.False(t, f.fetchCalled)
+
+	status := s.Status()
+	assert.True(t, status.Paused)
+	assert.Equal(t, "router swap", status.PauseReason)
+	assert.Nil(t, status.PausedUntil)
+
+	// The pause survives a restart
+	cfg.RunOnce = true
+	restarted := New(cfg, f, logger, WithPauseStore(store))
+	require.NoError(t, restarted.Run(context.Background()))
+	assert.False(t, f.fetchCalled)
+
+	// Resuming allows runs again
+	require.NoError(t, restarted.Resume())
+	_, err = restarted.fetch(context.Background(), "schedule")
+	require.NoError(t, err)
+	assert.True(t, f.fetchCalled)
+
+	persisted, err := store.PauseState()
+	require.NoError(t, err)
+	assert.False(t, persisted.Paused)
+}
+
+func TestPause_Expires(t *testing.T) {
+	cfg := config.Config{Timezone: "UTC", ScheduleTime: "23:59", OverlapPolicy: config.OverlapSkip}
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "ip_log.log"))
+	f := &mockFetcher{}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, f, logger, WithPauseStore(store))
+
+	pause, err := s.Pause("", time.Hour)
+	require.NoError(t, err)
+	until, err := time.Parse(time.RFC3339, pause.Until)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), until, 5*time.Second)
+
+	status := s.Status()
+	require.NotNil(t, status.PausedUntil)
+	assert.True(t, until.Equal(*status.PausedUntil))
+
+	// Runs resume once the pause has expired
+	s.mu.Lock()
+	s.pause.Until = time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	s.mu.Unlock()
+
+	_, err = s.fetch(context.Background(), "schedule")
+	require.NoError(t, err)
+	assert.True(t, f.fetchCalled)
+	assert.False(t, s.Status().Paused)
+
+	persisted, err := store.PauseState()
+	require.NoError(t, err)
+	assert.False(t, persisted.Paused)
+}
+
+func TestFetch_Maintenance(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	window := now.Add(-time.Hour).Format(time.RFC3339) + "/" + now.Add(time.Hour).Format(time.RFC3339)
+	cfg := config.Config{
+		Timezone:           "UTC",
+		ScheduleTime:       "23:59",
+		OverlapPolicy:      config.OverlapSkip,
+		MaintenanceWindows: []string{"0 0 1 1 * 1h", window},
+	}
+	f := &mockFetcher{}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	s := New(cfg, f, logger)
+
+	// Runs are skipped during a window
+	_, err := s.fetch(context.Background(), "schedule")
+	assert.ErrorIs(t, err, ErrMaintenance)
+	assert.False(t, f.fetchCalled)
+	assert.Equal(t, window, s.Status().Maintenance)
+
+	// And made once it is over
+	require.NoError(t, s.Reload(config.Config{Timezone: "UTC", ScheduleTime: "23:59", OverlapPolicy: config.OverlapSkip}))
+	_, err = s.fetch(context.Background(), "schedule")
+	require.NoError(t, err)
+	assert.True(t, f.fetchCalled)
+	assert.Empty(t, s.Status().Maintenance)
+}
@@ -0,0 +1,133 @@
+// Package server runs the HTTP server for the health, status and admin
+// endpoints.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+)
+
+// Server is an HTTP server bound to its listener.
+type Server struct {
+	server   *http.Server
+	listener net.Listener
+	tls      bool
+	cfg      config.Config
+	logger   *slog.Logger
+}
+
+// Listen binds HTTP_ADDR and loads the TLS certificate, if configured, so
+// that problems are reported at startup. HTTP_ADDR is a TCP host:port, or a
+// Unix socket path prefixed with "unix:".
+func Listen(cfg config.Config, handler http.Handler, logger *slog.Logger) (*Server, error) {
+
+	s := &Server{
+		server: &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: cfg.HTTPReadTimeout,
+			ReadTimeout:       cfg.HTTPReadTimeout,
+			WriteTimeout:      cfg.HTTPWriteTimeout,
+			ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		},
+		cfg:    cfg,
+		logger: logger,
+	}
+
+	if cfg.HTTPTLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.HTTPTLSCertFile, cfg.HTTPTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		s.server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		s.tls = true
+	}
+
+	listener, err := listen(cfg.HTTPAddr)
+	if err != nil {
+		return nil, err
+	}
+	s.listener = listener
+
+	return s, nil
+}
+
+// listen opens a TCP or Unix socket listener for addr.
+func listen(addr string) (net.Listener, error) {
+
+	path, unix := strings.CutPrefix(addr, config.UnixAddrPrefix)
+	if !unix {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		return listener, nil
+	}
+
+	// A socket left behind by an unclean exit would block the bind, but
+	// never remove anything that is not a socket
+	if info, err := os.Lstat(path); err == nil && info.Mode().Type() == fs.ModeSocket {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	return listener, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve serves requests until ctx is cancelled, then shuts down gracefully:
+// in-flight requests get HTTP_SHUTDOWN_TIMEOUT to finish before their
+// connections are closed. It returns an error if serving fails.
+func (s *Server) Serve(ctx context.Context) error {
+
+	s.logger.Info("Starting HTTP server", "addr", s.cfg.HTTPAddr, "tls", s.tls)
+
+	served := make(chan error, 1)
+	go func() {
+		if s.tls {
+			served <- s.server.ServeTLS(s.listener, "", "")
+		} else {
+			served <- s.server.Serve(s.listener)
+		}
+	}()
+
+	select {
+	case err := <-served:
+		return fmt.Errorf("HTTP server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.HTTPShutdownTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		s.logger.Warn("HTTP server did not shut down in time, closing connections", "error", err)
+		s.server.Close()
+	}
+
+	if err := <-served; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("HTTP server failed: %w", err)
+	}
+
+	s.logger.Info("HTTP server stopped")
+	return nil
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testConfig returns the HTTP settings for a server on addr.
+func testConfig(addr string) config.Config {
+	return config.Config{
+		HTTPAddr:            addr,
+		HTTPReadTimeout:     time.Second,
+		HTTPWriteTimeout:    time.Second,
+		HTTPShutdownTimeout: time.Second,
+	}
+}
+
+// serve runs s until the returned function is called, which waits for Serve
+// to return and hands back its error.
+func serve(t *testing.T, s *Server) func() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(ctx)
+	}()
+
+	return func() error {
+		cancel()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("Serve did not return")
+			return nil
+		}
+	}
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+})
+
+func get(t *testing.T, client *http.Client, url string) string {
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestServe_TCP(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	s, err := Listen(testConfig("127.0.0.1:0"), okHandler, logger)
+	require.NoError(t, err)
+	stop := serve(t, s)
+
+	assert.Equal(t, "OK", get(t, http.DefaultClient, "http://"+s.Addr().String()))
+
+	require.NoError(t, stop())
+
+	// The listener is closed after shutdown
+	_, err = http.Get("http://" + s.Addr().String())
+	assert.Error(t, err)
+}
+
+func TestListen_AddressInUse(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	s, err := Listen(testConfig("127.0.0.1:0"), okHandler, logger)
+	require.NoError(t, err)
+	defer s.listener.Close()
+
+	_, err = Listen(testConfig(s.Addr().String()), okHandler, logger)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to listen on")
+}
+
+func TestServe_GracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	logger := slog.New(slog.DiscardHandler)
+	s, err := Listen(testConfig("127.0.0.1:0"), handler, logger)
+	require.NoError(t, err)
+	stop := serve(t, s)
+
+	// A request in flight at shutdown is allowed to finish
+	body := make(chan string)
+	go func() {
+		body <- get(t, http.DefaultClient, "http://"+s.Addr().String())
+	}()
+	<-started
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- stop()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	assert.Equal(t, "done", <-body)
+	require.NoError(t, <-stopped)
+}
+
+func TestServe_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zonocaller.sock")
+
+	// A stale socket from an unclean exit is replaced
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	logger := slog.New(slog.DiscardHandler)
+	s, err := Listen(testConfig("unix:"+path), okHandler, logger)
+	require.NoError(t, err)
+	stop := serve(t, s)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", path)
+		},
+	}}
+	assert.Equal(t, "OK", get(t, client, "http://localhost/"))
+
+	require.NoError(t, stop())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "socket should be removed on shutdown")
+}
+
+func TestListen_UnixNotSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("keep me"), 0600))
+
+	// Only sockets are removed
+	_, err := Listen(testConfig("unix:"+path), okHandler, slog.New(slog.DiscardHandler))
+	require.Error(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "keep me", string(data))
+}
+
+// writeCertificate writes a self-signed certificate for 127.0.0.1 and its
+// key to dir.
+func writeCertificate(t *testing.T, dir string) (certFile, keyFile string, pool *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "zonocaller test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return certFile, keyFile, pool
+}
+
+func TestServe_TLS(t *testing.T) {
+	certFile, keyFile, pool := writeCertificate(t, t.TempDir())
+	cfg := testConfig("127.0.0.1:0")
+	cfg.HTTPTLSCertFile = certFile
+	cfg.HTTPTLSKeyFile = keyFile
+
+	logger := slog.New(slog.DiscardHandler)
+	s, err := Listen(cfg, okHandler, logger)
+	require.NoError(t, err)
+	stop := serve(t, s)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	assert.Equal(t, "OK", get(t, client, "https://"+s.Addr().String()))
+
+	require.NoError(t, stop())
+}
+
+func TestListen_BadCertificate(t *testing.T) {
+	cfg := testConfig("127.0.0.1:0")
+	cfg.HTTPTLSCertFile = filepath.Join(t.TempDir(), "missing.pem")
+	cfg.HTTPTLSKeyFile = filepath.Join(t.TempDir(), "missing.key")
+
+	_, err := Listen(cfg, okHandler, slog.New(slog.DiscardHandler))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to load TLS certificate")
+}
@@ -0,0 +1,382 @@
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// Bucket names in the bbolt database.
+var (
+	lastIPBucket  = []byte("last_ip")
+	hostsBucket   = []byte("hosts")
+	historyBucket = []byte("history")
+	metaBucket    = []byte("meta")
+)
+
+// Keys in the meta bucket.
+var (
+	lastRunKey   = []byte("last_run")
+	pauseKey     = []byte("pause")
+	historyCount = []byte("history_count")
+)
+
+// BoltStore keeps state in an embedded bbolt database.
+type BoltStore struct {
+	db        *bbolt.DB
+	retention Retention
+}
+
+// OpenBoltStore opens or creates the bbolt database at path. History older
+// or longer than retention allows is deleted after every write; MaxSize does
+// not apply.
+func OpenBoltStore(path string, retention Retention) (*BoltStore, error) {
+
+	// Fail instead of waiting forever if another process holds the database
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		if errors.Is(err, bbolt.ErrTimeout) {
+			return nil, fmt.Errorf("%s is in use by another instance", path)
+		}
+
+		return nil, fmt.Errorf("failed to open state database %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{lastIPBucket, hostsBucket, historyBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialise state database: %w", err)
+	}
+
+	return &BoltStore{db: db, retention: retention}, nil
+}
+
+// OpenBoltStoreReadOnly opens the bbolt database at path for reading. Writes
+// to the returned store fail.
+func OpenBoltStoreReadOnly(path string) (*BoltStore, error) {
+
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		if errors.Is(err, bbolt.ErrTimeout) {
+			return nil, fmt.Errorf("%s is in use by another instance", path)
+		}
+
+		return nil, fmt.Errorf("failed to open state database %s: %w", path, err)
+	}
+
+	// A database that was never written has no buckets yet
+	err = db.View(func(tx *bbolt.Tx) error {
+		if tx.Bucket(historyBucket) == nil {
+			return fmt.Errorf("state database %s is not initialised", path)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// LastEntry returns the last recorded entry of family.
+func (s *BoltStore) LastEntry(family Family) (Entry, error) {
+
+	var entry Entry
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(lastIPBucket).Get([]byte(family))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &entry)
+	})
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to read last IP: %w", err)
+	}
+
+	return entry, nil
+}
+
+// Record appends entry to the history, stores it as the last entry of its
+// IP's family, and deletes history that retention no longer keeps.
+func (s *BoltStore) Record(entry Entry) error {
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		history := tx.Bucket(historyBucket)
+		seq, err := history.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		// Big-endian keys keep the history in insertion order
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		if _, err := addHistoryCount(tx, 1); err != nil {
+			return err
+		}
+		if err := history.Put(key, data); err != nil {
+			return err
+		}
+
+		if family := FamilyOf(entry.IP); family != "" {
+			if err := tx.Bucket(lastIPBucket).Put([]byte(family), data); err != nil {
+				return err
+			}
+		}
+
+		return s.applyRetention(tx, time.Now())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record run: %w", err)
+	}
+
+	return nil
+}
+
+// HostValue returns the value last pushed to host.
+func (s *BoltStore) HostValue(host string) (string, error) {
+
+	var value HostValue
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(hostsBucket).Get([]byte(host))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &value)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read host %s: %w", host, err)
+	}
+
+	return value.Value, nil
+}
+
+// SetHostValue records that value was pushed to host.
+func (s *BoltStore) SetHostValue(host, value string, at time.Time) error {
+
+	data, err := json.Marshal(HostValue{Value: value, UpdatedAt: at.Format(time.RFC3339)})
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(hostsBucket).Put([]byte(host), data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record host %s: %w", host, err)
+	}
+
+	return nil
+}
+
+// LastRun returns when the last run started.
+func (s *BoltStore) LastRun() (time.Time, error) {
+
+	var at time.Time
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		// Databases written by older versions have no meta bucket
+		meta := tx.Bucket(metaBucket)
+		if meta == nil {
+			return nil
+		}
+
+		data := meta.Get(lastRunKey)
+		if data == nil {
+			return nil
+		}
+		return at.UnmarshalText(data)
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read last run time: %w", err)
+	}
+
+	return at, nil
+}
+
+// SetLastRun records when the last run started.
+func (s *BoltStore) SetLastRun(at time.Time) error {
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(lastRunKey, []byte(at.Format(time.RFC3339)))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record last run time: %w", err)
+	}
+
+	return nil
+}
+
+// PauseState returns the paused state.
+func (s *BoltStore) PauseState() (Pause, error) {
+
+	var pause Pause
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if meta == nil {
+			return nil
+		}
+
+		data := meta.Get(pauseKey)
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &pause)
+	})
+	if err != nil {
+		return Pause{}, fmt.Errorf("failed to read pause state: %w", err)
+	}
+
+	return pause, nil
+}
+
+// SetPauseState records the paused state.
+func (s *BoltStore) SetPauseState(pause Pause) error {
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if !pause.Paused {
+			return meta.Delete(pauseKey)
+		}
+
+		data, err := json.Marshal(pause)
+		if err != nil {
+			return err
+		}
+		return meta.Put(pauseKey, data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record pause state: %w", err)
+	}
+
+	return nil
+}
+
+// History returns every recorded entry, oldest first.
+func (s *BoltStore) History() ([]Entry, error) {
+
+	var entries []Entry
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(historyBucket).ForEach(func(_, data []byte) error {
+			var entry Entry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	return entries, nil
+}
+
+// applyRetention deletes the history entries that retention drops at now.
+// Entries are dropped oldest first, so the walk stops at the first entry
+// that is kept. The newest entry of each family is always kept and skipped.
+func (s *BoltStore) applyRetention(tx *bbolt.Tx, now time.Time) error {
+
+	if s.retention.MaxAge <= 0 && s.retention.MaxEntries <= 0 {
+		return nil
+	}
+
+	history := tx.Bucket(historyBucket)
+	lastIP := tx.Bucket(lastIPBucket)
+
+	excess := 0
+	if s.retention.MaxEntries > 0 {
+		count, err := addHistoryCount(tx, 0)
+		if err != nil {
+			return err
+		}
+		excess = count - s.retention.MaxEntries
+	}
+
+	cutoff := now.Add(-s.retention.MaxAge)
+	cursor := history.Cursor()
+	for key, data := cursor.First(); key != nil; {
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+
+		if family := FamilyOf(entry.IP); family != "" && bytes.Equal(data, lastIP.Get([]byte(family))) {
+			key, data = cursor.Next()
+			continue
+		}
+
+		// Keep entries whose age cannot be told
+		expired := false
+		if s.retention.MaxAge > 0 {
+			at, err := time.Parse(time.RFC3339, entry.Timestamp)
+			expired = err == nil && at.Before(cutoff)
+		}
+		if excess <= 0 && !expired {
+			return nil
+		}
+
+		key = append([]byte(nil), key...)
+		if err := cursor.Delete(); err != nil {
+			return err
+		}
+		if _, err := addHistoryCount(tx, -1); err != nil {
+			return err
+		}
+		excess--
+
+		// Seek past the deleted key; Next may skip an entry after Delete
+		key, data = cursor.Seek(key)
+	}
+
+	return nil
+}
+
+// addHistoryCount adds delta to the number of history entries kept in the
+// meta bucket and returns the new count. Databases written by older
+// versions have no count, so their entries are counted once.
+func addHistoryCount(tx *bbolt.Tx, delta int) (int, error) {
+
+	meta := tx.Bucket(metaBucket)
+
+	var count int
+	if data := meta.Get(historyCount); len(data) == 8 {
+		count = int(binary.BigEndian.Uint64(data))
+	} else {
+		cursor := tx.Bucket(historyBucket).Cursor()
+		for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+			count++
+		}
+	}
+	count += delta
+
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(count))
+	if err := meta.Put(historyCount, data); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Close closes the database.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,123 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltStore(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "state.db")
+	store, err := OpenBoltStore(path, Retention{})
+	require.NoError(t, err)
+
+	// Empty database
+	entry, err := store.LastEntry(FamilyIPv4)
+	require.NoError(t, err)
+	assert.Empty(t, entry.IP)
+
+	at := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.1", Timestamp: at.Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "2001:db8::1", Timestamp: at.Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.2", Timestamp: at.Add(time.Hour).Format(time.RFC3339)}))
+	require.NoError(t, store.SetHostValue("a.example.com", "192.168.1.2", at))
+	require.NoError(t, store.SetLastRun(at))
+	require.NoError(t, store.Close())
+
+	// State survives reopening the database
+	store, err = OpenBoltStore(path, Retention{})
+	require.NoError(t, err)
+	defer store.Close()
+
+	entry, err = store.LastEntry(FamilyIPv4)
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.2", entry.IP)
+
+	entry, err = store.LastEntry(FamilyIPv6)
+	require.NoError(t, err)
+	assert.Equal(t, "2001:db8::1", entry.IP)
+
+	value, err := store.HostValue("a.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.2", value)
+
+	lastRun, err := store.LastRun()
+	require.NoError(t, err)
+	assert.True(t, at.Equal(lastRun))
+
+	entries, err := store.History()
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{IP: "192.168.1.1", Timestamp: "2025-08-30T12:00:00Z"},
+		{IP: "2001:db8::1", Timestamp: "2025-08-30T12:00:00Z"},
+		{IP: "192.168.1.2", Timestamp: "2025-08-30T13:00:00Z"},
+	}, entries)
+}
+
+func TestBoltStore_Locked(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "state.db")
+	store, err := OpenBoltStore(path, Retention{})
+	require.NoError(t, err)
+	defer store.Close()
+
+	// A second open fails instead of blocking
+	_, err = OpenBoltStore(path, Retention{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "in use by another instance")
+}
+
+func TestOpenBoltStoreReadOnly(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "state.db")
+	store, err := OpenBoltStore(path, Retention{})
+	require.NoError(t, err)
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.1", Timestamp: "2025-08-30T12:00:00Z"}))
+
+	// The running instance holds the database
+	_, err = OpenBoltStoreReadOnly(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "in use by another instance")
+	require.NoError(t, store.Close())
+
+	store, err = OpenBoltStoreReadOnly(path)
+	require.NoError(t, err)
+	defer store.Close()
+
+	entries, err := store.History()
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestBoltStore_PauseState(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "state.db")
+	store, err := OpenBoltStore(path, Retention{})
+	require.NoError(t, err)
+
+	pause, err := store.PauseState()
+	require.NoError(t, err)
+	assert.False(t, pause.Paused)
+
+	paused := Pause{Paused: true, Reason: "router swap", Since: "2025-08-30T12:00:00Z"}
+	require.NoError(t, store.SetPauseState(paused))
+	require.NoError(t, store.Close())
+
+	// The paused state survives reopening the database
+	store, err = OpenBoltStore(path, Retention{})
+	require.NoError(t, err)
+	defer store.Close()
+
+	pause, err = store.PauseState()
+	require.NoError(t, err)
+	assert.Equal(t, paused, pause)
+
+	require.NoError(t, store.SetPauseState(Pause{}))
+	pause, err = store.PauseState()
+	require.NoError(t, err)
+	assert.Equal(t, Pause{}, pause)
+}
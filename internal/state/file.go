@@ -0,0 +1,517 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// chunkSize is how much of the log is read at a time when scanning backwards.
+const chunkSize = 4096
+
+// FileStore keeps the history as JSON lines in OUTPUT_FILE, and the values
+// pushed to each host in a small JSON file next to it.
+type FileStore struct {
+	mu        sync.Mutex
+	path      string
+	statePath string
+	lockFile  *os.File
+	retention Retention
+	logger    *slog.Logger
+
+	// What compaction needs to know about the log, kept up to date on each
+	// write so the log is only read in full when an entry is due to go
+	index *retentionIndex
+}
+
+// fileState is the contents of the host state file.
+type fileState struct {
+	Hosts   map[string]HostValue `json:"hosts"`
+	LastRun string               `json:"last_run,omitempty"`
+	Pause   *Pause               `json:"pause,omitempty"`
+}
+
+// NewFileStore creates a store backed by the JSON-lines log at path. Files
+// are created on first write. The store is not locked; use OpenFileStore
+// when other instances may share the files.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path:      path,
+		statePath: path + ".state.json",
+		logger:    slog.New(slog.DiscardHandler),
+	}
+}
+
+// OpenFileStore creates a store backed by the JSON-lines log at path and
+// takes an exclusive lock on it, held until Close. It fails if another
+// instance holds the lock. A last line left incomplete by a crash is removed.
+// The log is rotated and compacted according to retention after every write.
+func OpenFileStore(path string, retention Retention, logger *slog.Logger) (*FileStore, error) {
+
+	s := NewFileStore(path)
+	s.retention = retention
+	s.logger = logger
+
+	lockFile, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := lock(lockFile); err != nil {
+		lockFile.Close()
+		if errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("%s is in use by another instance", path)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	s.lockFile = lockFile
+
+	if err := s.repair(); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// LastEntry reads the log backwards from the end, so only the most recent
+// entries are read however long the log grows.
+func (s *FileStore) LastEntry(family Family) (Entry, error) {
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Entry{}, nil
+		}
+
+		return Entry{}, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	var last Entry
+	var skipped int
+	err = scanBackward(file, info.Size(), func(line []byte) bool {
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			skipped++
+			return true
+		}
+
+		if FamilyOf(entry.IP) == family {
+			last = entry
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if skipped > 0 {
+		s.logger.Warn("Skipped unreadable lines in IP log", "file", s.path, "lines", skipped)
+	}
+
+	return last, nil
+}
+
+// Record appends entry to the log and syncs it to disk, then applies the
+// retention settings.
+func (s *FileStore) Record(entry Entry) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Open or create the file if needed
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	jsonData, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	if _, err := file.Write(append(jsonData, '\n')); err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	return s.applyRetention(entry, time.Now())
+}
+
+// HostValue returns the value last pushed to host.
+func (s *FileStore) HostValue(host string) (string, error) {
+
+	st, err := s.readState()
+	if err != nil {
+		return "", err
+	}
+
+	return st.Hosts[host].Value, nil
+}
+
+// SetHostValue records value for host, replacing the state file atomically.
+func (s *FileStore) SetHostValue(host, value string, at time.Time) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.readState()
+	if err != nil {
+		return err
+	}
+
+	st.Hosts[host] = HostValue{Value: value, UpdatedAt: at.Format(time.RFC3339)}
+
+	return s.writeState(st)
+}
+
+// LastRun returns when the last run started.
+func (s *FileStore) LastRun() (time.Time, error) {
+
+	st, err := s.readState()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if st.LastRun == "" {
+		return time.Time{}, nil
+	}
+
+	at, err := time.Parse(time.RFC3339, st.LastRun)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse last run time %q: %w", st.LastRun, err)
+	}
+
+	return at, nil
+}
+
+// SetLastRun records when the last run started, replacing the state file
+// atomically.
+func (s *FileStore) SetLastRun(at time.Time) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.readState()
+	if err != nil {
+		return err
+	}
+
+	st.LastRun = at.Format(time.RFC3339)
+
+	return s.writeState(st)
+}
+
+// History reads every entry in the log. Lines that are not valid entries are skipped.
+func (s *FileStore) History() ([]Entry, error) {
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	var skipped int
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+				skipped++
+			}
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+
+	if skipped > 0 {
+		s.logger.Warn("Skipped unreadable lines in IP log", "file", s.path, "lines", skipped)
+	}
+
+	return entries, nil
+}
+
+// Close releases the lock taken by OpenFileStore. Other files are only held
+// open while in use.
+func (s *FileStore) Close() error {
+
+	if s.lockFile == nil {
+		return nil
+	}
+
+	err := s.lockFile.Close()
+	s.lockFile = nil
+
+	return err
+}
+
+// repair removes an incomplete last line, left when a crash interrupted a
+// write, so the next entry starts on a line of its own. A last line that is
+// a valid entry but lacks its newline gets one instead.
+func (s *FileStore) repair() error {
+
+	file, err := os.OpenFile(s.path, os.O_RDWR, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	// Keep everything up to and including the last newline
+	end := info.Size()
+	keep, err := endOfLastLine(file, end)
+	if err != nil {
+		return err
+	}
+
+	if keep == end {
+		return nil
+	}
+
+	// A complete entry that only lacks its newline, e.g. after a hand edit,
+	// is kept
+	tail := make([]byte, end-keep)
+	if _, err := file.ReadAt(tail, keep); err != nil {
+		return fmt.Errorf("failed to read log file: %w", err)
+	}
+
+	var entry Entry
+	if json.Unmarshal(tail, &entry) == nil {
+		s.logger.Info("Adding missing newline to last line of IP log", "file", s.path)
+
+		if _, err := file.WriteAt([]byte("\n"), end); err != nil {
+			return fmt.Errorf("failed to repair log file: %w", err)
+		}
+
+		if err := file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync log file: %w", err)
+		}
+
+		return nil
+	}
+
+	s.logger.Warn("Removing incomplete last line from IP log", "file", s.path, "bytes", end-keep)
+
+	if err := file.Truncate(keep); err != nil {
+		return fmt.Errorf("failed to truncate log file: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync log file: %w", err)
+	}
+
+	return nil
+}
+
+// readState reads the host state file. A missing file is an empty state.
+func (s *FileStore) readState() (*fileState, error) {
+
+	st := &fileState{Hosts: map[string]HostValue{}}
+
+	data, err := os.ReadFile(s.statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", s.statePath, err)
+	}
+
+	if st.Hosts == nil {
+		st.Hosts = map[string]HostValue{}
+	}
+
+	return st, nil
+}
+
+// PauseState returns the paused state.
+func (s *FileStore) PauseState() (Pause, error) {
+
+	st, err := s.readState()
+	if err != nil {
+		return Pause{}, err
+	}
+
+	if st.Pause == nil {
+		return Pause{}, nil
+	}
+
+	return *st.Pause, nil
+}
+
+// SetPauseState records the paused state, replacing the state file
+// atomically.
+func (s *FileStore) SetPauseState(pause Pause) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.readState()
+	if err != nil {
+		return err
+	}
+
+	st.Pause = nil
+	if pause.Paused {
+		st.Pause = &pause
+	}
+
+	return s.writeState(st)
+}
+
+// writeState replaces the host state file with st. The caller must hold s.mu.
+func (s *FileStore) writeState(st *fileState) error {
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	// Write to a temporary file and rename it over the old one
+	err = writeAtomic(s.statePath, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
+
+// endOfLastLine returns the offset just past the last newline in the first
+// size bytes of file, or 0 if there is none.
+func endOfLastLine(file *os.File, size int64) (int64, error) {
+
+	for offset := size; offset > 0; {
+		n := min(int64(chunkSize), offset)
+		offset -= n
+
+		chunk := make([]byte, n)
+		if _, err := file.ReadAt(chunk, offset); err != nil {
+			return 0, fmt.Errorf("failed to read log file: %w", err)
+		}
+
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			return offset + int64(i) + 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// scanBackward calls fn with each non-empty line of the first size bytes of
+// file, last line first, until fn returns false.
+func scanBackward(file *os.File, size int64, fn func(line []byte) bool) error {
+
+	// rest holds the start of a line whose beginning is in an earlier chunk
+	var rest []byte
+	for offset := size; offset > 0; {
+		n := min(int64(chunkSize), offset)
+		offset -= n
+
+		chunk := make([]byte, n)
+		if _, err := file.ReadAt(chunk, offset); err != nil {
+			return fmt.Errorf("failed to read log file: %w", err)
+		}
+
+		buf := append(chunk, rest...)
+		for {
+			i := bytes.LastIndexByte(buf, '\n')
+			if i < 0 {
+				break
+			}
+
+			if line := buf[i+1:]; len(bytes.TrimSpace(line)) > 0 && !fn(line) {
+				return nil
+			}
+			buf = buf[:i]
+		}
+		rest = buf
+	}
+
+	if len(bytes.TrimSpace(rest)) > 0 {
+		fn(rest)
+	}
+
+	return nil
+}
+
+// writeAtomic writes a file through write and syncs it, then renames it over
+// path so a crash leaves either the old or the new contents.
+func writeAtomic(path string, write func(w io.Writer) error) error {
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(path))
+}
@@ -0,0 +1,249 @@
+package state
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore_LastEntry(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "ip_log.log")
+	store := NewFileStore(path)
+
+	// Missing file
+	entry, err := store.LastEntry(FamilyIPv4)
+	require.NoError(t, err)
+	assert.Empty(t, entry.IP)
+
+	now := time.Now()
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.1", Timestamp: now.Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "2001:db8::1", Timestamp: now.Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.2", Timestamp: now.Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "2001:db8::2", Timestamp: now.Format(time.RFC3339)}))
+
+	entry, err = store.LastEntry(FamilyIPv4)
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.2", entry.IP)
+
+	entry, err = store.LastEntry(FamilyIPv6)
+	require.NoError(t, err)
+	assert.Equal(t, "2001:db8::2", entry.IP)
+}
+
+func TestFileStore_LastEntryReadsExistingLog(t *testing.T) {
+
+	// A log written by earlier versions, with a broken line at the end
+	path := filepath.Join(t.TempDir(), "ip_log.log")
+	contents := `{"ip":"192.168.1.1","Timestamp":"2025-08-30T12:00:00Z"}
+{"ip":"192.168.1.2","Timestamp":"2025-08-31T12:00:00Z"}
+{"ip":"192.168.1.3","Time`
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+
+	entry, err := NewFileStore(path).LastEntry(FamilyIPv4)
+	require.NoError(t, err)
+	assert.Equal(t, Entry{IP: "192.168.1.2", Timestamp: "2025-08-31T12:00:00Z"}, entry)
+}
+
+func TestFileStore_LastEntryAcrossChunks(t *testing.T) {
+
+	// Enough entries that the scan crosses several chunk boundaries
+	path := filepath.Join(t.TempDir(), "ip_log.log")
+	var b strings.Builder
+	fmt.Fprintln(&b, `{"ip":"2001:db8::1","Timestamp":"2025-08-30T12:00:00Z"}`)
+	for i := range 1000 {
+		fmt.Fprintf(&b, "{\"ip\":\"10.0.%d.%d\",\"Timestamp\":\"2025-08-30T12:00:00Z\"}\n", i/256, i%256)
+	}
+	require.NoError(t, os.WriteFile(path, []byte(b.String()), 0644))
+
+	store := NewFileStore(path)
+
+	entry, err := store.LastEntry(FamilyIPv4)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.3.231", entry.IP)
+
+	entry, err = store.LastEntry(FamilyIPv6)
+	require.NoError(t, err)
+	assert.Equal(t, "2001:db8::1", entry.IP)
+}
+
+func TestFileStore_HostValue(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "ip_log.log")
+	store := NewFileStore(path)
+
+	value, err := store.HostValue("a.example.com")
+	require.NoError(t, err)
+	assert.Empty(t, value)
+
+	require.NoError(t, store.SetHostValue("a.example.com", "192.168.1.1", time.Now()))
+	require.NoError(t, store.SetHostValue("b.example.com", "192.168.1.2", time.Now()))
+	require.NoError(t, store.SetHostValue("a.example.com", "192.168.1.3", time.Now()))
+
+	// Values survive reopening the store
+	store = NewFileStore(path)
+
+	value, err = store.HostValue("a.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.3", value)
+
+	value, err = store.HostValue("b.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.2", value)
+
+	assert.FileExists(t, path+".state.json")
+}
+
+func TestFileStore_LastRun(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "ip_log.log")
+	store := NewFileStore(path)
+
+	at, err := store.LastRun()
+	require.NoError(t, err)
+	assert.True(t, at.IsZero())
+
+	// The last run is kept alongside host values
+	started := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, store.SetHostValue("a.example.com", "192.168.1.1", started))
+	require.NoError(t, store.SetLastRun(started))
+
+	store = NewFileStore(path)
+	at, err = store.LastRun()
+	require.NoError(t, err)
+	assert.True(t, started.Equal(at))
+
+	value, err := store.HostValue("a.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.1", value)
+}
+
+func TestFileStore_History(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "ip_log.log")
+	store := NewFileStore(path)
+
+	entries, err := store.History()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	at := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.1", Timestamp: at.Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.2", Timestamp: at.Add(time.Hour).Format(time.RFC3339)}))
+
+	entries, err = store.History()
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{IP: "192.168.1.1", Timestamp: "2025-08-30T12:00:00Z"},
+		{IP: "192.168.1.2", Timestamp: "2025-08-30T13:00:00Z"},
+	}, entries)
+}
+
+func TestOpenFileStore_Lock(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "ip_log.log")
+	store, err := OpenFileStore(path, Retention{}, slog.Default())
+	require.NoError(t, err)
+
+	// A second instance is refused while the first holds the lock
+	_, err = OpenFileStore(path, Retention{}, slog.Default())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "in use by another instance")
+
+	// Closing releases it
+	require.NoError(t, store.Close())
+	store, err = OpenFileStore(path, Retention{}, slog.Default())
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+}
+
+func TestOpenFileStore_RepairsTruncatedLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		expected string
+	}{
+		{
+			name:     "Truncated last line",
+			contents: "{\"ip\":\"192.168.1.1\",\"Timestamp\":\"2025-08-30T12:00:00Z\"}\n{\"ip\":\"192.168.1.2\",\"Time",
+			expected: "{\"ip\":\"192.168.1.1\",\"Timestamp\":\"2025-08-30T12:00:00Z\"}\n",
+		},
+		{
+			name:     "Only a truncated line",
+			contents: "{\"ip\":\"192.168.1.2\",\"Time",
+			expected: "",
+		},
+		{
+			name:     "Complete last line without newline",
+			contents: "{\"ip\":\"192.168.1.1\",\"Timestamp\":\"2025-08-30T12:00:00Z\"}\n{\"ip\":\"192.168.1.2\",\"Timestamp\":\"2025-08-31T12:00:00Z\"}",
+			expected: "{\"ip\":\"192.168.1.1\",\"Timestamp\":\"2025-08-30T12:00:00Z\"}\n{\"ip\":\"192.168.1.2\",\"Timestamp\":\"2025-08-31T12:00:00Z\"}\n",
+		},
+		{
+			name:     "Only a complete line without newline",
+			contents: "{\"ip\":\"192.168.1.2\",\"Timestamp\":\"2025-08-31T12:00:00Z\"}",
+			expected: "{\"ip\":\"192.168.1.2\",\"Timestamp\":\"2025-08-31T12:00:00Z\"}\n",
+		},
+		{
+			name:     "Complete file",
+			contents: "{\"ip\":\"192.168.1.1\",\"Timestamp\":\"2025-08-30T12:00:00Z\"}\n",
+			expected: "{\"ip\":\"192.168.1.1\",\"Timestamp\":\"2025-08-30T12:00:00Z\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ip_log.log")
+			require.NoError(t, os.WriteFile(path, []byte(tt.contents), 0644))
+
+			store, err := OpenFileStore(path, Retention{}, slog.Default())
+			require.NoError(t, err)
+			defer store.Close()
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(data))
+
+			// The next entry starts on its own line
+			require.NoError(t, store.Record(Entry{IP: "192.168.1.3", Timestamp: time.Now().Format(time.RFC3339)}))
+			entry, err := store.LastEntry(FamilyIPv4)
+			require.NoError(t, err)
+			assert.Equal(t, "192.168.1.3", entry.IP)
+		})
+	}
+}
+
+func TestFileStore_PauseState(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "ip_log.log")
+	store := NewFileStore(path)
+
+	pause, err := store.PauseState()
+	require.NoError(t, err)
+	assert.False(t, pause.Paused)
+
+	// The paused state is kept alongside the last run
+	paused := Pause{Paused: true, Reason: "router swap", Since: "2025-08-30T12:00:00Z", Until: "2025-08-30T14:00:00Z"}
+	require.NoError(t, store.SetLastRun(time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)))
+	require.NoError(t, store.SetPauseState(paused))
+
+	store = NewFileStore(path)
+	pause, err = store.PauseState()
+	require.NoError(t, err)
+	assert.Equal(t, paused, pause)
+
+	at, err := store.LastRun()
+	require.NoError(t, err)
+	assert.False(t, at.IsZero())
+
+	require.NoError(t, store.SetPauseState(Pause{}))
+	pause, err = store.PauseState()
+	require.NoError(t, err)
+	assert.Equal(t, Pause{}, pause)
+}
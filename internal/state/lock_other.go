@@ -0,0 +1,21 @@
+//go:build !unix
+
+package state
+
+import (
+	"errors"
+	"os"
+)
+
+// errLocked is returned by lock when another process holds the lock.
+var errLocked = errors.New("locked by another process")
+
+// lock is a no-op where flock is not available.
+func lock(file *os.File) error {
+	return nil
+}
+
+// syncDir is a no-op where directories cannot be synced.
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,278 @@
+package state
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+)
+
+// rotatedTimeFormat dates rotated log files, e.g. ip_log.log.20250830T120000Z.gz.
+const rotatedTimeFormat = "20060102T150405Z"
+
+// Retention bounds how much history is kept. Zero values disable a limit.
+type Retention struct {
+	// MaxAge drops entries, and rotated files, older than this.
+	MaxAge time.Duration
+	// MaxEntries keeps only the newest entries.
+	MaxEntries int
+	// MaxSize rotates the file backend's log once it grows past this many bytes.
+	MaxSize int64
+}
+
+// RetentionFrom returns the IP_LOG_* retention settings in cfg.
+func RetentionFrom(cfg config.Config) Retention {
+	return Retention{
+		MaxAge:     cfg.IPLogMaxAge,
+		MaxEntries: cfg.IPLogMaxEntries,
+		MaxSize:    cfg.IPLogMaxSize,
+	}
+}
+
+// retain reports, for each of entries (oldest first), whether the retention
+// settings keep it at now, and how many are kept. The newest entry of each
+// family is always kept so the last IP is never forgotten.
+func (r Retention) retain(entries []Entry, now time.Time) ([]bool, int) {
+
+	keep := make([]bool, len(entries))
+	kept := 0
+	seen := map[Family]bool{}
+
+	// Walk from the newest entry so MaxEntries keeps the most recent ones
+	cutoff := now.Add(-r.MaxAge)
+	for i := len(entries) - 1; i >= 0; i-- {
+		family := FamilyOf(entries[i].IP)
+		if !seen[family] {
+			seen[family] = true
+			keep[i] = true
+			kept++
+			continue
+		}
+
+		if r.MaxEntries > 0 && kept >= r.MaxEntries {
+			continue
+		}
+
+		// Keep entries whose age cannot be told
+		if r.MaxAge > 0 {
+			at, err := time.Parse(time.RFC3339, entries[i].Timestamp)
+			if err == nil && at.Before(cutoff) {
+				continue
+			}
+		}
+
+		keep[i] = true
+		kept++
+	}
+
+	return keep, kept
+}
+
+// retentionIndex tracks, for the entries of a log, what decides whether
+// retention would drop any of them.
+type retentionIndex struct {
+	count int
+	// oldest is the earliest timestamp of an entry that is not the newest
+	// of its family, or zero if there is none
+	oldest time.Time
+	// newest holds the timestamp of the newest entry of each family, zero
+	// if it cannot be told
+	newest map[Family]time.Time
+}
+
+// newRetentionIndex indexes entries, oldest first.
+func newRetentionIndex(entries []Entry) *retentionIndex {
+
+	index := &retentionIndex{newest: map[Family]time.Time{}}
+	for _, entry := range entries {
+		index.add(entry)
+	}
+
+	return index
+}
+
+// add indexes entry, appended after the indexed entries.
+func (x *retentionIndex) add(entry Entry) {
+
+	x.count++
+
+	// The previous newest entry of the family is no longer always kept
+	family := FamilyOf(entry.IP)
+	if at, ok := x.newest[family]; ok && !at.IsZero() && (x.oldest.IsZero() || at.Before(x.oldest)) {
+		x.oldest = at
+	}
+
+	at, _ := time.Parse(time.RFC3339, entry.Timestamp)
+	x.newest[family] = at
+}
+
+// due reports whether r would drop an indexed entry at now.
+func (x *retentionIndex) due(r Retention, now time.Time) bool {
+
+	if r.MaxEntries > 0 && x.count > r.MaxEntries {
+		return true
+	}
+
+	return r.MaxAge > 0 && !x.oldest.IsZero() && x.oldest.Before(now.Add(-r.MaxAge))
+}
+
+// applyRetention rotates the log when it is too large, compacts it to the
+// entries retention keeps, and removes rotated files older than MaxAge.
+// entry is the one just appended.
+func (s *FileStore) applyRetention(entry Entry, now time.Time) error {
+
+	if s.retention.MaxSize > 0 {
+		info, err := os.Stat(s.path)
+		if err != nil {
+			return fmt.Errorf("failed to stat log file: %w", err)
+		}
+
+		if info.Size() > s.retention.MaxSize {
+			if err := s.rotate(now); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.retention.MaxAge > 0 || s.retention.MaxEntries > 0 {
+		if err := s.compact(entry, now); err != nil {
+			return err
+		}
+	}
+
+	if s.retention.MaxAge > 0 {
+		return s.removeRotated(now.Add(-s.retention.MaxAge))
+	}
+
+	return nil
+}
+
+// rotate compresses the log into a dated .gz file next to it and starts a
+// new log holding only the last entry of each family, so the last IP is
+// still known after rotation.
+func (s *FileStore) rotate(now time.Time) error {
+
+	rotated := fmt.Sprintf("%s.%s.gz", s.path, now.UTC().Format(rotatedTimeFormat))
+
+	src, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer src.Close()
+
+	err = writeAtomic(rotated, func(w io.Writer) error {
+		gz := gzip.NewWriter(w)
+		if _, err := io.Copy(gz, src); err != nil {
+			return err
+		}
+		return gz.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	var last []Entry
+	for _, family := range []Family{FamilyIPv4, FamilyIPv6} {
+		entry, err := s.LastEntry(family)
+		if err != nil {
+			return err
+		}
+		if entry.IP != "" {
+			last = append(last, entry)
+		}
+	}
+
+	if err := writeEntries(s.path, last); err != nil {
+		return fmt.Errorf("failed to start new log file: %w", err)
+	}
+
+	// The new log is short, so index it again when next compacting
+	s.index = nil
+
+	s.logger.Info("Rotated IP log", "file", s.path, "rotated", rotated)
+
+	return nil
+}
+
+// compact rewrites the log with only the entries retention keeps. The log is
+// only read when the index shows an entry is due to be dropped; entry is the
+// one just appended.
+func (s *FileStore) compact(entry Entry, now time.Time) error {
+
+	if s.index != nil {
+		s.index.add(entry)
+		if !s.index.due(s.retention, now) {
+			return nil
+		}
+	}
+
+	entries, err := s.History()
+	if err != nil {
+		return err
+	}
+
+	keep, kept := s.retention.retain(entries, now)
+	if kept == len(entries) {
+		s.index = newRetentionIndex(entries)
+		return nil
+	}
+
+	var retained []Entry
+	for i, entry := range entries {
+		if keep[i] {
+			retained = append(retained, entry)
+		}
+	}
+
+	if err := writeEntries(s.path, retained); err != nil {
+		return fmt.Errorf("failed to compact log file: %w", err)
+	}
+	s.index = newRetentionIndex(retained)
+
+	s.logger.Info("Compacted IP log", "file", s.path, "removed", len(entries)-kept, "kept", kept)
+
+	return nil
+}
+
+// removeRotated deletes rotated log files last modified before cutoff.
+func (s *FileStore) removeRotated(cutoff time.Time) error {
+
+	rotated, err := filepath.Glob(s.path + ".*.gz")
+	if err != nil {
+		return fmt.Errorf("failed to list rotated log files: %w", err)
+	}
+
+	for _, name := range rotated {
+		info, err := os.Stat(name)
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+
+		if err := os.Remove(name); err != nil {
+			return fmt.Errorf("failed to remove rotated log file: %w", err)
+		}
+
+		s.logger.Info("Removed old rotated IP log", "file", name)
+	}
+
+	return nil
+}
+
+// writeEntries atomically replaces the log at path with entries.
+func writeEntries(path string, entries []Entry) error {
+
+	return writeAtomic(path, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
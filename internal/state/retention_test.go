@@ -0,0 +1,215 @@
+package state
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetention_Retain(t *testing.T) {
+
+	now := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{IP: "192.168.1.1", Timestamp: now.Add(-72 * time.Hour).Format(time.RFC3339)},
+		{IP: "192.168.1.2", Timestamp: "not a time"},
+		{IP: "192.168.1.3", Timestamp: now.Add(-2 * time.Hour).Format(time.RFC3339)},
+		{IP: "192.168.1.4", Timestamp: now.Add(-time.Hour).Format(time.RFC3339)},
+		{IP: "2001:db8::1", Timestamp: now.Add(-96 * time.Hour).Format(time.RFC3339)},
+	}
+
+	tests := []struct {
+		name      string
+		retention Retention
+		expected  []bool
+	}{
+		{
+			name:     "No limits",
+			expected: []bool{true, true, true, true, true},
+		},
+		{
+			name:      "Max age",
+			retention: Retention{MaxAge: 24 * time.Hour},
+			expected:  []bool{false, true, true, true, true},
+		},
+		{
+			name:      "Max entries",
+			retention: Retention{MaxEntries: 3},
+			expected:  []bool{false, false, true, true, true},
+		},
+		{
+			name:      "Max age and entries",
+			retention: Retention{MaxAge: 90 * time.Minute, MaxEntries: 3},
+			expected:  []bool{false, true, false, true, true},
+		},
+		{
+			name:      "Newest of each family is kept",
+			retention: Retention{MaxAge: time.Minute, MaxEntries: 1},
+			expected:  []bool{false, false, false, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, _ := tt.retention.retain(entries, now)
+			assert.Equal(t, tt.expected, keep)
+		})
+	}
+}
+
+func TestFileStore_MaxEntries(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "ip_log.log")
+	store, err := OpenFileStore(path, Retention{MaxEntries: 2}, slog.Default())
+	require.NoError(t, err)
+	defer store.Close()
+
+	at := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	for _, ip := range []string{"192.168.1.1", "192.168.1.2", "192.168.1.3"} {
+		require.NoError(t, store.Record(Entry{IP: ip, Timestamp: at.Format(time.RFC3339)}))
+	}
+
+	entries, err := store.History()
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{IP: "192.168.1.2", Timestamp: "2025-08-30T12:00:00Z"},
+		{IP: "192.168.1.3", Timestamp: "2025-08-30T12:00:00Z"},
+	}, entries)
+}
+
+func TestFileStore_MaxAge(t *testing.T) {
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ip_log.log")
+	now := time.Now()
+
+	// An old rotated file and a recent one
+	oldRotated := path + ".20240101T000000Z.gz"
+	newRotated := path + ".20250101T000000Z.gz"
+	require.NoError(t, os.WriteFile(oldRotated, nil, 0644))
+	require.NoError(t, os.WriteFile(newRotated, nil, 0644))
+	require.NoError(t, os.Chtimes(oldRotated, now.Add(-48*time.Hour), now.Add(-48*time.Hour)))
+
+	store, err := OpenFileStore(path, Retention{MaxAge: 24 * time.Hour}, slog.Default())
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.1", Timestamp: now.Add(-48 * time.Hour).Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.2", Timestamp: now.Format(time.RFC3339)}))
+
+	entries, err := store.History()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "192.168.1.2", entries[0].IP)
+
+	assert.NoFileExists(t, oldRotated)
+	assert.FileExists(t, newRotated)
+}
+
+func TestFileStore_Rotate(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "ip_log.log")
+	store, err := OpenFileStore(path, Retention{MaxSize: 100}, slog.Default())
+	require.NoError(t, err)
+	defer store.Close()
+
+	at := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.1", Timestamp: at.Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.2", Timestamp: at.Format(time.RFC3339)}))
+
+	// The second write passed the limit, so both entries were rotated out
+	// and only the last one is kept in the new log
+	entries, err := store.History()
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{{IP: "192.168.1.2", Timestamp: "2025-08-30T12:00:00Z"}}, entries)
+
+	rotated, err := filepath.Glob(path + ".*.gz")
+	require.NoError(t, err)
+	require.Len(t, rotated, 1)
+
+	file, err := os.Open(rotated[0])
+	require.NoError(t, err)
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"ip\":\"192.168.1.1\",\"timestamp\":\"2025-08-30T12:00:00Z\"}\n{\"ip\":\"192.168.1.2\",\"timestamp\":\"2025-08-30T12:00:00Z\"}\n", string(data))
+}
+
+func TestBoltStore_Retention(t *testing.T) {
+
+	store, err := OpenBoltStore(filepath.Join(t.TempDir(), "state.db"), Retention{MaxAge: 24 * time.Hour, MaxEntries: 2})
+	require.NoError(t, err)
+	defer store.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.1", Timestamp: now.Add(-48 * time.Hour).Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.2", Timestamp: now.Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.3", Timestamp: now.Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.4", Timestamp: now.Format(time.RFC3339)}))
+
+	entries, err := store.History()
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{IP: "192.168.1.3", Timestamp: now.Format(time.RFC3339)},
+		{IP: "192.168.1.4", Timestamp: now.Format(time.RFC3339)},
+	}, entries)
+}
+
+func TestFileStore_RetentionIndex(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "ip_log.log")
+	store, err := OpenFileStore(path, Retention{MaxAge: 24 * time.Hour, MaxEntries: 3}, slog.Default())
+	require.NoError(t, err)
+	defer store.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, store.Record(Entry{IP: "2001:db8::1", Timestamp: now.Add(-48 * time.Hour).Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.1", Timestamp: now.Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.2", Timestamp: now.Format(time.RFC3339)}))
+
+	// The old IPv6 entry is the newest of its family, so nothing is due
+	assert.False(t, store.index.due(store.retention, now))
+
+	// Once replaced, it is due and dropped
+	require.NoError(t, store.Record(Entry{IP: "2001:db8::2", Timestamp: now.Format(time.RFC3339)}))
+	entries, err := store.History()
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{IP: "192.168.1.1", Timestamp: now.Format(time.RFC3339)},
+		{IP: "192.168.1.2", Timestamp: now.Format(time.RFC3339)},
+		{IP: "2001:db8::2", Timestamp: now.Format(time.RFC3339)},
+	}, entries)
+	assert.Equal(t, 3, store.index.count)
+	assert.False(t, store.index.due(store.retention, now))
+}
+
+func TestBoltStore_RetentionSkipsNewestOfFamily(t *testing.T) {
+
+	store, err := OpenBoltStore(filepath.Join(t.TempDir(), "state.db"), Retention{MaxAge: 24 * time.Hour, MaxEntries: 2})
+	require.NoError(t, err)
+	defer store.Close()
+
+	// The oldest entry is the only IPv6 one, so it is kept and the walk
+	// carries on past it
+	now := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, store.Record(Entry{IP: "2001:db8::1", Timestamp: now.Add(-48 * time.Hour).Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.1", Timestamp: now.Add(-48 * time.Hour).Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.2", Timestamp: now.Format(time.RFC3339)}))
+	require.NoError(t, store.Record(Entry{IP: "192.168.1.3", Timestamp: now.Format(time.RFC3339)}))
+
+	entries, err := store.History()
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{IP: "2001:db8::1", Timestamp: now.Add(-48 * time.Hour).Format(time.RFC3339)},
+		{IP: "192.168.1.3", Timestamp: now.Format(time.RFC3339)},
+	}, entries)
+}
@@ -0,0 +1,151 @@
+package state
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+)
+
+// Family is the address family of an IP.
+type Family string
+
+const (
+	FamilyIPv4 Family = "ipv4"
+	FamilyIPv6 Family = "ipv6"
+)
+
+// EntryVersion is the schema version of entries written by this version.
+// Version 1 entries hold only the IP and timestamp.
+const EntryVersion = 2
+
+// Entry records a single run in the history.
+type Entry struct {
+	Version    int          `json:"v,omitempty"`
+	RunID      string       `json:"run_id,omitempty"`
+	IP         string       `json:"ip"`
+	Timestamp  string       `json:"timestamp"`
+	PreviousIP string       `json:"previous_ip,omitempty"`
+	Changed    bool         `json:"changed,omitempty"`
+	Forced     bool         `json:"forced,omitempty"`
+	Sources    []string     `json:"sources,omitempty"`
+	Hosts      []HostResult `json:"hosts,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	DurationMS int64        `json:"duration_ms,omitempty"`
+}
+
+// HostResult is the outcome of updating a single DNS host during a run.
+type HostResult struct {
+	Host  string `json:"host"`
+	Value string `json:"value"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// SchemaVersion returns the schema version e was written with. Version 1
+// entries predate the version field and were written with a "Timestamp"
+// key, which decoding matches case-insensitively.
+func (e Entry) SchemaVersion() int {
+
+	if e.Version == 0 {
+		return 1
+	}
+
+	return e.Version
+}
+
+// HostValue is the value last pushed to a DNS host.
+type HostValue struct {
+	Value     string `json:"value"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// Pause is the persisted paused state. Times are RFC 3339; an empty Until
+// means paused until resumed.
+type Pause struct {
+	Paused bool   `json:"paused"`
+	Reason string `json:"reason,omitempty"`
+	Since  string `json:"since,omitempty"`
+	Until  string `json:"until,omitempty"`
+}
+
+// Store keeps the state the fetcher needs between runs.
+type Store interface {
+	// LastEntry returns the most recently recorded entry of family, or an
+	// empty entry if none.
+	LastEntry(family Family) (Entry, error)
+
+	// Record appends entry to the history and, if it holds an IP, makes it the
+	// last entry of that IP's family.
+	Record(entry Entry) error
+
+	// HostValue returns the value last pushed to host, or "" if none.
+	HostValue(host string) (string, error)
+
+	// SetHostValue records that value was pushed to host.
+	SetHostValue(host, value string, at time.Time) error
+
+	// History returns every recorded entry, oldest first.
+	History() ([]Entry, error)
+
+	// LastRun returns when the last run started, or the zero time if none.
+	LastRun() (time.Time, error)
+
+	// SetLastRun records when the last run started.
+	SetLastRun(at time.Time) error
+
+	// PauseState returns the paused state, or the zero Pause if not paused.
+	PauseState() (Pause, error)
+
+	// SetPauseState records the paused state.
+	SetPauseState(pause Pause) error
+
+	// Close releases the store.
+	Close() error
+}
+
+// Open opens the store selected by STATE_BACKEND. Both backends hold an
+// exclusive lock until the store is closed.
+func Open(cfg config.Config, logger *slog.Logger) (Store, error) {
+
+	switch cfg.StateBackend {
+	case config.StateBackendFile, "":
+		return OpenFileStore(cfg.OutputFile, RetentionFrom(cfg), logger)
+	case config.StateBackendBolt:
+		return OpenBoltStore(cfg.StatePath, RetentionFrom(cfg))
+	default:
+		return nil, fmt.Errorf("unknown state backend %q", cfg.StateBackend)
+	}
+}
+
+// OpenReadOnly opens the store selected by STATE_BACKEND for reading while
+// another instance may be running. The file backend is read without taking
+// its lock; the bolt backend fails if a running instance holds the database.
+func OpenReadOnly(cfg config.Config) (Store, error) {
+
+	switch cfg.StateBackend {
+	case config.StateBackendFile, "":
+		return NewFileStore(cfg.OutputFile), nil
+	case config.StateBackendBolt:
+		return OpenBoltStoreReadOnly(cfg.StatePath)
+	default:
+		return nil, fmt.Errorf("unknown state backend %q", cfg.StateBackend)
+	}
+}
+
+// FamilyOf returns the address family of ip, or "" if ip is not an IP address.
+func FamilyOf(ip string) Family {
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	if parsed.To4() != nil {
+		return FamilyIPv4
+	}
+
+	return FamilyIPv6
+}
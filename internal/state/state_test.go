@@ -0,0 +1,71 @@
+package state
+
+import (
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/Drakx/ZonoCaller/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFamilyOf(t *testing.T) {
+	assert.Equal(t, FamilyIPv4, FamilyOf("192.168.1.1"))
+	assert.Equal(t, FamilyIPv6, FamilyOf("2001:db8::1"))
+	assert.Equal(t, Family(""), FamilyOf("not-an-ip"))
+}
+
+func TestEntry_ReadsVersion1(t *testing.T) {
+
+	// Lines written before the schema was versioned
+	var entry Entry
+	require.NoError(t, json.Unmarshal([]byte(`{"ip":"192.168.1.1","Timestamp":"2025-08-30T12:00:00Z"}`), &entry))
+	assert.Equal(t, Entry{IP: "192.168.1.1", Timestamp: "2025-08-30T12:00:00Z"}, entry)
+	assert.Equal(t, 1, entry.SchemaVersion())
+}
+
+func TestEntry_RoundTrip(t *testing.T) {
+
+	entry := Entry{
+		Version:    EntryVersion,
+		RunID:      "0f8fad5b-d9cb-469f-a165-70867728950e",
+		IP:         "192.168.1.2",
+		Timestamp:  "2025-08-30T12:00:00Z",
+		PreviousIP: "192.168.1.1",
+		Changed:    true,
+		Sources:    []string{"https://api.ipify.org?format=json"},
+		Hosts: []HostResult{
+			{Host: "a.example.com", Value: "192.168.1.2", OK: true},
+			{Host: "b.example.com", Value: "192.168.1.2", Error: "unexpected status: 400 Bad Request"},
+		},
+		DurationMS: 250,
+	}
+
+	data, err := json.Marshal(entry)
+	require.NoError(t, err)
+
+	var decoded Entry
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, entry, decoded)
+	assert.Equal(t, EntryVersion, decoded.SchemaVersion())
+}
+
+func TestOpen(t *testing.T) {
+
+	dir := t.TempDir()
+
+	store, err := Open(config.Config{StateBackend: config.StateBackendFile, OutputFile: filepath.Join(dir, "ip_log.log")}, slog.Default())
+	require.NoError(t, err)
+	assert.IsType(t, &FileStore{}, store)
+	require.NoError(t, store.Close())
+
+	store, err = Open(config.Config{StateBackend: config.StateBackendBolt, StatePath: filepath.Join(dir, "state.db")}, slog.Default())
+	require.NoError(t, err)
+	assert.IsType(t, &BoltStore{}, store)
+	require.NoError(t, store.Close())
+
+	_, err = Open(config.Config{StateBackend: "sqlite"}, slog.Default())
+	require.Error(t, err)
+}